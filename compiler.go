@@ -3,6 +3,7 @@ package eval
 import (
 	"fmt"
 	"math"
+	"reflect"
 	"sort"
 )
 
@@ -19,6 +20,10 @@ const (
 	ReportEvent            CompileOption = "report_event"
 	InfixNotation          CompileOption = "infix_notation"
 	AllowUndefinedVariable CompileOption = "allow_undefined_variable"
+	NestedField            CompileOption = "nested_field"
+	StrictNull             CompileOption = "strict_null"
+	StrictEquality         CompileOption = "strict_equality"
+	UnixTime               CompileOption = "unix_time"
 )
 
 type optimizer func(config *Config, root *astNode)
@@ -61,6 +66,16 @@ func copyConfig(dst, src *Config) {
 	for _, op := range src.StatelessOperators {
 		dst.StatelessOperators = append(dst.StatelessOperators, op)
 	}
+	if src.DecimalRounding != nil {
+		r := *src.DecimalRounding
+		dst.DecimalRounding = &r
+	}
+	for k, v := range src.Orderings {
+		dst.Orderings[k] = v
+	}
+	if src.Collation != CollationBinary {
+		dst.Collation = src.Collation
+	}
 }
 
 type Option func(conf *Config)
@@ -75,6 +90,27 @@ var (
 	EnableReportEvent Option = func(c *Config) {
 		c.CompileOptions[ReportEvent] = true
 	}
+	// EnableNestedField fetches the dotted variables (e.g. address.country) by walking into the nested fields
+	// of the root variable (address), instead of fetching them by the whole names
+	EnableNestedField Option = func(c *Config) {
+		c.CompileOptions[NestedField] = true
+	}
+	// EnableStrictNull reports an error when the eq and ne operators compare null params,
+	// instead of treating null as only equal to null
+	EnableStrictNull Option = func(c *Config) {
+		c.CompileOptions[StrictNull] = true
+	}
+	// EnableStrictEquality reports an error when the eq and ne operators compare the params of mismatched types,
+	// e.g. a string and an integer, instead of treating them as not equal
+	EnableStrictEquality Option = func(c *Config) {
+		c.CompileOptions[StrictEquality] = true
+	}
+	// EnableUnixTime is the compatibility mode of the times, the time operators (e.g. date and datetime) return
+	// the Unix seconds, and the time.Time variables are converted to the Unix seconds, as the int64 times of
+	// the previous versions. Otherwise, the times are time values which keep the nanoseconds and the time zones
+	EnableUnixTime Option = func(c *Config) {
+		c.CompileOptions[UnixTime] = true
+	}
 	Optimizations = func(enable bool, opts ...CompileOption) Option {
 		return func(c *Config) {
 			if len(opts) == 0 || (len(opts) == 1 && opts[0] == Optimize) {
@@ -92,6 +128,20 @@ var (
 		c.CompileOptions[InfixNotation] = true
 	}
 
+	// RoundDecimal sets the scale and the rounding mode of the decimal division
+	RoundDecimal = func(scale int32, mode RoundingMode) Option {
+		return func(c *Config) {
+			c.DecimalRounding = &DecimalRounding{Scale: scale, Mode: mode}
+		}
+	}
+
+	// StringCollation sets the collation of the string ordering
+	StringCollation = func(collation Collation) Option {
+		return func(c *Config) {
+			c.Collation = collation
+		}
+	}
+
 	// RegVarAndOp registers variables and operators to config
 	RegVarAndOp = func(vals map[string]interface{}) Option {
 		return func(c *Config) {
@@ -126,6 +176,7 @@ func NewConfig(opts ...Option) *Config {
 		CompileOptions:     make(map[CompileOption]bool),
 		CostsMap:           make(map[string]float64),
 		StatelessOperators: []string{},
+		Orderings:          make(map[reflect.Type]Ordering),
 	}
 	for _, opt := range opts {
 		opt(conf)
@@ -147,6 +198,16 @@ type Config struct {
 	// StatelessOperators will be used in optimizeConstantFolding,
 	// so please make sure when adding new operators into StatelessOperators
 	StatelessOperators []string
+
+	// DecimalRounding is the rounding of the decimal division,
+	// the quotient is rounded to 8 digits with RoundHalfUp when it is nil
+	DecimalRounding *DecimalRounding
+
+	// Orderings are the orderings of the user types used by the comparison operators, see RegisterOrdering
+	Orderings map[reflect.Type]Ordering
+
+	// Collation is the collation of the string ordering, strings are compared byte-wise by default
+	Collation Collation
 }
 
 func (cc *Config) getCosts(nodeType uint8, nodeName string) float64 {
@@ -164,7 +225,7 @@ func (cc *Config) getCosts(nodeType uint8, nodeName string) float64 {
 	}
 
 	switch nodeType {
-	case variable:
+	case variable, probe:
 		if v, exist := cc.CostsMap[variableNode]; exist {
 			return v
 		}
@@ -220,7 +281,7 @@ func optimizeReduceNesting(cc *Config, root *astNode) {
 	rootOpType := isAndOpNode(n)
 	for _, child := range root.children {
 		cn := child.node
-		if typ := cn.getNodeType(); typ == constant || typ == variable {
+		if typ := cn.getNodeType(); typ == constant || typ == variable || typ == local {
 			children = append(children, child)
 			continue
 		}
@@ -303,9 +364,9 @@ func calculateNodeCosts(conf *Config, root *astNode) {
 
 	// base cost
 	switch nodeType {
-	case constant:
+	case constant, local:
 		baseCost = inlinedCall
-	case variable:
+	case variable, probe:
 		baseCost = funcCall
 	case fastOperator:
 		baseCost = funcCall
@@ -315,12 +376,15 @@ func calculateNodeCosts(conf *Config, root *astNode) {
 		baseCost = loops*float64(len(children)+1) + funcCall
 	case cond:
 		baseCost = loops * 4
+	case scope, loopHead, loopNext, multiCond, jumpTable:
+		baseCost = loops
 	default:
 		baseCost = 10
 	}
 
 	// operation cost
 	if nodeType == variable ||
+		nodeType == probe ||
 		nodeType == operator ||
 		nodeType == fastOperator {
 		operationCost = conf.getCosts(nodeType, n.value.(string))
@@ -343,6 +407,11 @@ func optimizeConstantFolding(cc *Config, root *astNode) {
 	}
 
 	n := root.node
+	if n.getNodeType() == scope {
+		foldScope(cc, root)
+		return
+	}
+
 	stateless, fn := isStatelessOp(cc, n)
 	if !stateless {
 		return
@@ -390,6 +459,71 @@ func optimizeConstantFolding(cc *Config, root *astNode) {
 	return
 }
 
+// foldScope evaluates the scope node at compile time when all its inputs are constant
+func foldScope(cc *Config, root *astNode) {
+	if !isConstNode(cc, root, make(map[*binding]bool)) {
+		return
+	}
+
+	// building the expression modifies the nodes, so build it with a copy of the nodes
+	cp := copyAstNode(root)
+	cr := check(cp)
+	if cr.err != nil {
+		return
+	}
+
+	res, err := buildExpr(&Config{}, cp, cr.size).Eval(nil)
+	if err != nil {
+		return
+	}
+	root.children = nil
+	root.node = &node{
+		flag:  constant,
+		value: res,
+	}
+}
+
+// isConstNode checks if the node only depends on constants and the local variables bound inside it
+func isConstNode(cc *Config, root *astNode, bound map[*binding]bool) bool {
+	n := root.node
+	switch n.getNodeType() {
+	case constant, cond, loopHead, loopNext, multiCond, jumpTable:
+	case local:
+		if !bound[n.value.(*binding)] {
+			return false
+		}
+	case operator, fastOperator:
+		if stateless, _ := isStatelessOp(cc, n); !stateless {
+			return false
+		}
+	case scope:
+		for _, b := range n.value.(*keywordInfo).bindings {
+			bound[b] = true
+		}
+	default:
+		return false
+	}
+
+	for _, child := range root.children {
+		if !isConstNode(cc, child, bound) {
+			return false
+		}
+	}
+	return true
+}
+
+func copyAstNode(root *astNode) *astNode {
+	n := *root.node
+	res := &astNode{
+		node:     &n,
+		children: make([]*astNode, len(root.children)),
+	}
+	for i, child := range root.children {
+		res.children[i] = copyAstNode(child)
+	}
+	return res
+}
+
 func isStatelessOp(c *Config, n *node) (bool, Operator) {
 	if typ := n.getNodeType(); typ != operator && typ != fastOperator {
 		return false, nil
@@ -400,11 +534,20 @@ func isStatelessOp(c *Config, n *node) (bool, Operator) {
 		return false, nil
 	}
 
-	// builtinOperators stateless functions
-	for _, so := range builtinStatelessOperations {
-		if so == op {
-			return true, builtinOperators[op]
+	// the collect keyword and the special forms are compiled to operator nodes
+	if op == string(keywordCollect) || isSpecialFormNode(n) {
+		return true, n.operator
+	}
+
+	// builtinOperators stateless functions, the now operator is never stateless
+	if isBuiltinOperator(c, op) {
+		for _, so := range builtinStatelessOperations {
+			if so == op {
+				// the node operator may be bound with the config, e.g. the decimal division
+				return true, n.operator
+			}
 		}
+		return false, nil
 	}
 
 	for _, so := range c.StatelessOperators {
@@ -485,6 +628,7 @@ func buildExpr(cc *Config, ast *astNode, size int) *Expr {
 	calAndSetNodes(e, ast)
 	calAndSetParentIndex(e, ast)
 	calAndSetStackSize(e)
+	calAndSetLocalSlots(e)
 	calAndSetShortCircuit(e)
 	calAndSetShortCircuitForRCO(e)
 
@@ -499,21 +643,66 @@ func calAndSetNodes(e *Expr, root *astNode) {
 	root.parentIdx = -1
 	n := root.node
 	switch n.getNodeType() {
-	case constant, variable:
+	case constant, variable, probe, local, loopHead, loopNext:
 		e.nodes = append(e.nodes, n)
 		root.idx = len(e.nodes) - 1
-	case operator:
+	case operator, scope, multiCond:
 		for _, child := range root.children {
 			calAndSetNodes(e, child)
 		}
 		e.nodes = append(e.nodes, n)
 		root.idx = len(e.nodes) - 1
+
+		if n.getNodeType() == multiCond {
+			// the end if nodes of the branches jump to the end of cond or switch
+			var ends []*astNode
+			if n.value == keywordSwitch {
+				// the branches and the end if nodes are the children of the jump table
+				for i, child := range root.children[1].children {
+					if i%2 == 1 {
+						ends = append(ends, child)
+					}
+				}
+			} else {
+				for _, clause := range root.children[:len(root.children)-1] {
+					ends = append(ends, clause.children[2])
+				}
+			}
+
+			for _, end := range ends {
+				end.node.scIdx = int16(root.idx - 1)
+			}
+		}
+
+		if n.getNodeType() == scope && n.value.(*keywordInfo).isLoop() {
+			var (
+				l    = len(root.children)
+				head = root.children[l-3]
+				next = root.children[l-1]
+			)
+			// the loop head jumps to the loop next node when the collection is empty,
+			// the loop next node jumps back to the loop head node to execute the loop body again
+			head.node.scIdx = int16(next.idx)
+			next.node.scIdx = int16(head.idx)
+		}
 	case fastOperator:
 		e.nodes = append(e.nodes, n)
 		root.idx = len(e.nodes) - 1
 		for _, child := range root.children {
 			calAndSetNodes(e, child)
 		}
+	case jumpTable:
+		e.nodes = append(e.nodes, n)
+		root.idx = len(e.nodes) - 1
+
+		// each branch is followed by an end if node, except the default branch
+		table := n.value.(*switchTable)
+		for i, child := range root.children {
+			if i%2 == 0 {
+				table.targets[i/2] = int16(len(e.nodes) - 1)
+			}
+			calAndSetNodes(e, child)
+		}
 	case cond:
 		if n.value == keywordIf {
 			var (
@@ -534,6 +723,21 @@ func calAndSetNodes(e *Expr, root *astNode) {
 
 			calAndSetNodes(e, falseBranch) // false branch
 			endIfNode.node.scIdx = int16(len(e.nodes) - 1)
+		} else if n.value == keywordCond {
+			var (
+				condNode  = root.children[0]
+				branch    = root.children[1]
+				endIfNode = root.children[2]
+			)
+
+			calAndSetNodes(e, condNode) // condition node
+
+			e.nodes = append(e.nodes, n) // check condition node result
+			root.idx = len(e.nodes) - 1
+
+			calAndSetNodes(e, branch)    // the branch of the clause
+			calAndSetNodes(e, endIfNode) // jump to the end of cond, it is set by the cond node
+			n.scIdx = int16(len(e.nodes) - 1)
 		} else {
 			e.nodes = append(e.nodes, n)
 			root.idx = len(e.nodes) - 1
@@ -591,16 +795,38 @@ func calAndSetStackSize(e *Expr) {
 
 		n := e.nodes[i]
 		switch n.getNodeType() {
-		case constant, variable, fastOperator:
+		case constant, variable, probe, local, fastOperator:
 			f[i] = f[prev] + 1
 		case operator:
 			f[i] = f[prev] - int16(n.childCnt) + 1
+		case scope:
+			if info := n.value.(*keywordInfo); info.isLoop() {
+				// pop the loop frame, the result is placed at the frame bottom
+				f[i] = f[prev] - (frameSize - 1)
+			} else {
+				// pop the local variables, the result of the scope body
+				// is moved to the slot of the first local variable
+				f[i] = f[prev] - int16(len(info.bindings))
+			}
+		case loopHead:
+			// push the loop frame, the collection (and the initial value of reduce)
+			// has been pushed by the previous nodes
+			f[i] = f[prev] + frameSize - n.value.(*keywordInfo).frameInputs()
+		case loopNext:
+			// pop the result of the loop body
+			f[i] = f[prev] - 1
 		case cond:
-			if n.value == keywordIf {
+			if n.value == keywordIf || n.value == keywordCond {
 				f[i] = f[prev] - 1
 			} else {
 				f[i] = f[prev]
 			}
+		case jumpTable:
+			// pop the switch subject
+			f[i] = f[prev] - 1
+		case multiCond:
+			// the result of the branch is the result of cond or switch
+			f[i] = f[prev]
 		}
 	}
 
@@ -612,6 +838,31 @@ func calAndSetStackSize(e *Expr) {
 	e.maxStackSize = maxStackSize
 }
 
+// calAndSetLocalSlots sets the operand stack slots of the local variables,
+// the values of local variables are placed at the bottom of their scope stack frame
+func calAndSetLocalSlots(e *Expr) {
+	for _, n := range e.nodes {
+		if n.getNodeType() != scope {
+			continue
+		}
+
+		info := n.value.(*keywordInfo)
+		if info.isLoop() {
+			// the loop variable is bound to the current element of the loop frame,
+			// the accumulator of reduce is bound to the accumulator of the loop frame
+			info.bindings[len(info.bindings)-1].slot = n.osTop + frameElem
+			if info.keyword == keywordReduce {
+				info.bindings[0].slot = n.osTop + frameAcc
+			}
+			continue
+		}
+
+		for i, b := range info.bindings {
+			b.slot = n.osTop + int16(i)
+		}
+	}
+}
+
 func calAndSetShortCircuit(e *Expr) {
 	var (
 		size = int16(len(e.nodes))
@@ -682,7 +933,8 @@ func calAndSetShortCircuit(e *Expr) {
 			}
 		}
 
-		if n.getNodeType() == cond {
+		// the scIdx of these nodes are the jump targets
+		if typ := n.getNodeType(); typ == cond || typ == loopHead || typ == loopNext {
 			continue
 		}
 
@@ -716,6 +968,13 @@ const (
 	OperatorNode     = NodeType(operator)
 	FastOperatorNode = NodeType(fastOperator)
 	CondNode         = NodeType(cond)
+	LocalNode        = NodeType(local)
+	ScopeNode        = NodeType(scope)
+	LoopHeadNode     = NodeType(loopHead)
+	LoopNextNode     = NodeType(loopNext)
+	MultiCondNode    = NodeType(multiCond)
+	JumpTableNode    = NodeType(jumpTable)
+	ProbeNode        = NodeType(probe)
 	EventNode        = NodeType(event)
 )
 
@@ -731,6 +990,20 @@ func (t NodeType) String() string {
 		return "fast_operator"
 	case CondNode:
 		return "cond"
+	case LocalNode:
+		return "local"
+	case ScopeNode:
+		return "scope"
+	case LoopHeadNode:
+		return "loop_head"
+	case LoopNextNode:
+		return "loop_next"
+	case MultiCondNode:
+		return "multi_cond"
+	case JumpTableNode:
+		return "jump_table"
+	case ProbeNode:
+		return "probe"
 	case EventNode:
 		return "event"
 	}
@@ -823,6 +1096,13 @@ func calAndSetEventNode(e *Expr) {
 			n.scIdx = realIdxes[n.scIdx]
 		}
 
+		if n.getNodeType() == jumpTable {
+			table := n.value.(*switchTable)
+			for j, target := range table.targets {
+				table.targets[j] = realIdxes[target]
+			}
+		}
+
 		p := parents[i]
 		if p == -1 {
 			continue
@@ -18,6 +18,7 @@ func TestCopyConfig(t *testing.T) {
 	assertNotNil(t, res.VariableKeyMap)
 	assertNotNil(t, res.CompileOptions)
 	assertNotNil(t, res.StatelessOperators)
+	assertNotNil(t, res.Orderings)
 
 	res = CopyConfig(&Config{})
 	assertNotNil(t, res)
@@ -120,6 +121,17 @@ func TestCopyConfig(t *testing.T) {
 	}
 
 	res = CopyConfig(cc)
+	assertEquals(t, res.DecimalRounding == nil, true)
+
+	cc.DecimalRounding = &DecimalRounding{Scale: 2, Mode: RoundHalfEven}
+	res = CopyConfig(cc)
+	assertEquals(t, *res.DecimalRounding, *cc.DecimalRounding)
+
+	cc.Collation = CollationCaseInsensitive
+	assertNil(t, RegisterOrdering(cc, func(a, b time.Weekday) int { return int(a - b) }))
+	res = CopyConfig(cc)
+	assertEquals(t, res.Collation, cc.Collation)
+	assertEquals(t, len(res.Orderings), 1)
 	assertEquals(t, res.ConstantMap, cc.ConstantMap)
 	assertEquals(t, res.VariableKeyMap, cc.VariableKeyMap)
 	assertEquals(t, res.CompileOptions, cc.CompileOptions)
@@ -357,6 +369,287 @@ func TestOptimizeConstantFolding(t *testing.T) {
 			},
 		},
 
+		{
+			expr: `(> (* 0.5 (+ 1 2)) 1.49)`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: true,
+			},
+		},
+		{
+			expr: `(/ 1 2.0)`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: 0.5,
+			},
+		},
+		{
+			expr: `(reduce acc 0 x (1 2 3) (+ acc x))`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: int64(6),
+			},
+		},
+		{
+			expr: `(collect 1 (+ 1 1) (reduce acc 0 x (collect 1 2) (+ acc x)))`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: []int64{1, 2, 3},
+			},
+		},
+		{
+			expr: `(let ((a 1)) (map x (1 2) (+ x a)))`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: []int64{2, 3},
+			},
+		},
+		{
+			expr: `(and (< "apple" "banana") (between "en-US" "en" "es"))`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: true,
+			},
+		},
+		{
+			// the folding compares strings with the configured collation
+			expr: `(< "Banana" "apple")`,
+			cc: &Config{
+				Collation: CollationCaseInsensitive,
+			},
+			ast: verifyNode{
+				tpy:  constant,
+				data: false,
+			},
+		},
+		{
+			expr: `(split (upper (concat "a," (trim " b "))) ",")`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: []string{"A", "B"},
+			},
+		},
+		{
+			expr: `(or (matches "a@corp.com" "@corp\\.com$") (= (extract "id-42" "id-(\\d+)") "42"))`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: true,
+			},
+		},
+		{
+			expr: `(has_bits (bor (shl 1 4) (band 0xFF (bnot 0x0F))) 0x30)`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: true,
+			},
+		},
+		{
+			expr: `(+ (nth (1 2 3) 1) (last (4 5)))`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: int64(7),
+			},
+		},
+		{
+			expr: `(add_duration (truncate_to_day 90061) 1d12h)`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: int64(86400*2 + 12*3600),
+			},
+		},
+		{
+			expr: `(= (truncate_to_day (in_tz (datetime "2022-05-06 23:30:00") "Asia/Tokyo")) (date "2022-05-07" "2006-01-02" "Asia/Tokyo"))`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: true,
+			},
+		},
+		{
+			expr: `(and (in_schedule (date "2022-05-02") "MON#1 MAY") (= (weekday (date "2022-05-02")) 1))`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: true,
+			},
+		},
+		{
+			expr: `(and (version_satisfies (semver "3.1.4") "^3.1") (> (semver "3.1.4") (semver "3.1.4-rc.1")))`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: true,
+			},
+		},
+		{
+			// it won't fold the durations relative to the current time
+			expr: `(> (since 0) 7d)`,
+			ast: verifyNode{
+				tpy:  operator,
+				data: ">",
+				children: []verifyNode{
+					{
+						tpy:  operator,
+						data: "since",
+						children: []verifyNode{
+							{tpy: constant, data: int64(0)},
+						},
+					},
+					{tpy: constant, data: int64(7 * 24 * 3600)},
+				},
+			},
+		},
+		{
+			// the builtin now is never folded, even if it is listed as a stateless operator
+			expr: `(= (today) (truncate_to_day (now)))`,
+			cc: &Config{
+				StatelessOperators: []string{"now"},
+			},
+			ast: verifyNode{
+				tpy:  operator,
+				data: "=",
+				children: []verifyNode{
+					{tpy: operator, data: "today"},
+					{
+						tpy:  operator,
+						data: "truncate_to_day",
+						children: []verifyNode{
+							{tpy: operator, data: "now"},
+						},
+					},
+				},
+			},
+		},
+		{
+			// the operator of the config shadows the builtin one, it is folded if it is stateless
+			expr: `(= (today) (truncate_to_day (now)))`,
+			cc: &Config{
+				StatelessOperators: []string{"now"},
+				OperatorMap: map[string]Operator{
+					"now": func(_ *Ctx, _ []Value) (Value, error) {
+						return int64(90061), nil
+					},
+				},
+			},
+			ast: verifyNode{
+				tpy:  operator,
+				data: "=",
+				children: []verifyNode{
+					{tpy: operator, data: "today"},
+					{tpy: constant, data: int64(86400)},
+				},
+			},
+		},
+		{
+			expr: `(in "gold" (GOLD SILVER))`,
+			cc: &Config{
+				ConstantMap: map[string]Value{
+					"GOLD":   "gold",
+					"SILVER": "silver",
+				},
+			},
+			ast: verifyNode{
+				tpy:  constant,
+				data: true,
+			},
+		},
+		{
+			expr: `(get {"US": (+ 5 5), "CA": 8} "US")`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: int64(10),
+			},
+		},
+		{
+			expr: `(first (nth ((1 2) (+ 1 2)) 0))`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: int64(1),
+			},
+		},
+		{
+			expr: `(default null 0)`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: int64(0),
+			},
+		},
+		{
+			expr: `(is_null null)`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: true,
+			},
+		},
+		{
+			expr: `(max 3 (* 2 4))`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: int64(8),
+			},
+		},
+		{
+			expr: `(* (decimal "19.99") 3)`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: mustDecimal(5997, 2),
+			},
+		},
+		{
+			expr: `(collect "a" v)`,
+			cc: &Config{
+				VariableKeyMap: map[string]VariableKey{
+					"v": VariableKey(1),
+				},
+			},
+			ast: verifyNode{
+				tpy:  operator,
+				data: "collect",
+				children: []verifyNode{
+					{tpy: constant, data: "a"},
+					{tpy: variable, data: "v"},
+				},
+			},
+		},
+		{
+			// it won't fold the loops which depend on variables
+			expr: `(reduce acc 0 x v (+ acc x))`,
+			cc: &Config{
+				VariableKeyMap: map[string]VariableKey{
+					"v": VariableKey(1),
+				},
+			},
+			ast: verifyNode{
+				tpy: scope,
+				data: &keywordInfo{
+					keyword:  keywordReduce,
+					bindings: []*binding{{name: "acc"}, {name: "x"}},
+				},
+				children: []verifyNode{
+					{tpy: variable, data: "v"},
+					{tpy: constant, data: int64(0)},
+					{
+						tpy: loopHead,
+						data: &keywordInfo{
+							keyword:  keywordReduce,
+							bindings: []*binding{{name: "acc"}, {name: "x"}},
+						},
+					},
+					{
+						tpy:  operator,
+						data: "+",
+						children: []verifyNode{
+							{tpy: local, data: &binding{name: "acc"}},
+							{tpy: local, data: &binding{name: "x"}},
+						},
+					},
+					{
+						tpy: loopNext,
+						data: &keywordInfo{
+							keyword:  keywordReduce,
+							bindings: []*binding{{name: "acc"}, {name: "x"}},
+						},
+					},
+				},
+			},
+		},
 		{
 			cc: &Config{
 				OperatorMap: map[string]Operator{
@@ -1343,10 +1636,123 @@ func TestCompile(t *testing.T) {
 				},
 			},
 		},
+		{
+			expr: `(let ((a v1)) (+ a 1))`,
+			cc: NewConfig(Optimizations(false), RegVarAndOp(map[string]interface{}{
+				"v1": 1,
+			})),
+			nodes: []*node{
+				{
+					flag:   variable,
+					osTop:  0,
+					scIdx:  0,
+					varKey: VariableKey(1),
+					value:  "v1",
+				},
+				{
+					flag:  local,
+					osTop: 1,
+					scIdx: 1,
+					value: &binding{name: "a", slot: 0},
+				},
+				{
+					flag:  constant,
+					osTop: 2,
+					scIdx: 2,
+					value: int64(1),
+				},
+				{
+					flag:     operator,
+					childCnt: 2,
+					osTop:    1,
+					scIdx:    3,
+					value:    "+",
+				},
+				{
+					flag:     scope,
+					childCnt: 2,
+					osTop:    0,
+					scIdx:    -1,
+					value: &keywordInfo{
+						keyword:  keywordLet,
+						bindings: []*binding{{name: "a", slot: 0}},
+					},
+				},
+			},
+		},
+		{
+			expr: `(switch v1 ("a" 1) (default 2))`,
+			cc: NewConfig(Optimizations(false), RegVarAndOp(map[string]interface{}{
+				"v1": 1,
+			})),
+			nodes: []*node{
+				{
+					flag:   variable,
+					osTop:  0,
+					scIdx:  0,
+					varKey: VariableKey(1),
+					value:  "v1",
+				},
+				{
+					flag:     jumpTable,
+					childCnt: 3,
+					osTop:    -1,
+					scIdx:    1,
+					value: &switchTable{
+						labels:  []Value{"a"},
+						cases:   map[Value]int{"a": 0},
+						targets: []int16{1, 3},
+					},
+				},
+				{
+					flag:  constant,
+					osTop: 0,
+					scIdx: 2,
+					value: int64(1),
+				},
+				{
+					flag:  cond,
+					osTop: 0,
+					scIdx: 4,
+					value: "fi",
+				},
+				{
+					flag:  constant,
+					osTop: 0,
+					scIdx: 4,
+					value: int64(2),
+				},
+				{
+					flag:     multiCond,
+					childCnt: 2,
+					osTop:    0,
+					scIdx:    -1,
+					value:    keywordSwitch,
+				},
+			},
+		},
 		{
 			expr:   `(and ()`,
 			errMsg: "parentheses unmatched error",
 		},
+		{
+			// the invalid constant pattern is reported with its position
+			expr:   `(and (= v 1) (matches v "a(b"))`,
+			cc:     NewConfig(RegVarAndOp(map[string]interface{}{"v": 1})),
+			errMsg: "missing closing ): `a(b` occurs at  (and (= v 1) (matches v [\"]a(b\"))",
+		},
+		{
+			// the invalid constant constraint is reported with its position
+			expr:   `(and (= v 1) (version_satisfies v "^1.2.3-"))`,
+			cc:     NewConfig(RegVarAndOp(map[string]interface{}{"v": 1})),
+			errMsg: "invalid version constraint \"^1.2.3-\": invalid semver: 1.2.3- occurs at  ... (= v 1) (version_satisfies v [\"]^1.2.3-\"))",
+		},
+		{
+			// the invalid constant schedule is reported with its position
+			expr:   `(and (= v 1) (in_schedule v "MON-FRY"))`,
+			cc:     NewConfig(RegVarAndOp(map[string]interface{}{"v": 1})),
+			errMsg: "invalid schedule \"MON-FRY\": unknown weekday FRY occurs at  (and (= v 1) (in_schedule v [\"]MON-FRY\"))",
+		},
 		{
 			expr:   fmt.Sprintf(`(+ %s)`, strings.Repeat(`1 `, 128)),
 			cc:     NewConfig(Optimizations(false)),
@@ -0,0 +1,256 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is a fixed-point decimal number, its value is coef * 10^(-scale).
+// It represents the monetary values exactly, e.g. the literal 12.50d is Decimal{coef: 1250, scale: 2}
+type Decimal struct {
+	coef  int64
+	scale int32
+}
+
+const maxDecimalScale = 18
+
+var errDecimalOverflow = errors.New("decimal overflow")
+
+type RoundingMode int
+
+const (
+	RoundHalfUp   RoundingMode = iota // rounds half away from zero, e.g. 2.5 -> 3, -2.5 -> -3
+	RoundHalfEven                     // rounds half to the even neighbor, e.g. 2.5 -> 2, 3.5 -> 4
+	RoundDown                         // rounds towards zero, e.g. 2.9 -> 2, -2.9 -> -2
+	RoundUp                           // rounds away from zero, e.g. 2.1 -> 3, -2.1 -> -3
+)
+
+// DecimalRounding is the rounding of the decimal division,
+// the quotient is rounded to Scale digits after the decimal point
+type DecimalRounding struct {
+	Scale int32
+	Mode  RoundingMode
+}
+
+var defaultDecimalRounding = DecimalRounding{Scale: 8, Mode: RoundHalfUp}
+
+// NewDecimal returns the decimal coef * 10^(-scale), the scale should be in [0, 18]
+func NewDecimal(coef int64, scale int32) (Decimal, error) {
+	if scale < 0 || scale > maxDecimalScale {
+		return Decimal{}, fmt.Errorf("decimal scale out of range: %d", scale)
+	}
+	return Decimal{coef: coef, scale: scale}, nil
+}
+
+// ParseDecimal parses the decimal string, e.g. "12.50", "-0.125", "100"
+func ParseDecimal(s string) (Decimal, error) {
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 {
+		return Decimal{}, fmt.Errorf("invalid decimal: %s", s)
+	}
+
+	intPart, fracPart, hasDot := strings.Cut(digits, ".")
+	if intPart == "" || (hasDot && fracPart == "") {
+		return Decimal{}, fmt.Errorf("invalid decimal: %s", s)
+	}
+
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return Decimal{}, fmt.Errorf("invalid decimal: %s", s)
+		}
+	}
+
+	if len(fracPart) > maxDecimalScale {
+		return Decimal{}, fmt.Errorf("decimal scale out of range: %s", s)
+	}
+
+	coef, err := strconv.ParseInt(s[:len(s)-len(digits)]+intPart+fracPart, 10, 64)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("invalid decimal: %s, %w", s, errDecimalOverflow)
+	}
+	return Decimal{coef: coef, scale: int32(len(fracPart))}, nil
+}
+
+func (d Decimal) String() string {
+	var (
+		neg = d.coef < 0
+		abs = uint64(d.coef)
+	)
+	if neg {
+		abs = uint64(-d.coef)
+	}
+
+	s := strconv.FormatUint(abs, 10)
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(s); pad > 0 {
+			s = strings.Repeat("0", pad) + s
+		}
+		s = s[:len(s)-int(d.scale)] + "." + s[len(s)-int(d.scale):]
+	}
+
+	if neg {
+		return "-" + s
+	}
+	return s
+}
+
+// Cmp compares the two decimals, it returns -1 if d < o, 0 if d == o, +1 if d > o
+func (d Decimal) Cmp(o Decimal) int {
+	if d.scale == o.scale {
+		switch {
+		case d.coef < o.coef:
+			return -1
+		case d.coef > o.coef:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	scale := maxInt32(d.scale, o.scale)
+	return d.rescale(scale).Cmp(o.rescale(scale))
+}
+
+// rescale returns the coefficient of the decimal in the larger scale
+func (d Decimal) rescale(scale int32) *big.Int {
+	v := big.NewInt(d.coef)
+	if scale > d.scale {
+		v.Mul(v, pow10(scale-d.scale))
+	}
+	return v
+}
+
+func (d Decimal) add(o Decimal) (Decimal, error) {
+	scale := maxInt32(d.scale, o.scale)
+	return decimalFromBig(new(big.Int).Add(d.rescale(scale), o.rescale(scale)), scale)
+}
+
+func (d Decimal) sub(o Decimal) (Decimal, error) {
+	scale := maxInt32(d.scale, o.scale)
+	return decimalFromBig(new(big.Int).Sub(d.rescale(scale), o.rescale(scale)), scale)
+}
+
+func (d Decimal) mul(o Decimal, mode RoundingMode) (Decimal, error) {
+	v := new(big.Int).Mul(big.NewInt(d.coef), big.NewInt(o.coef))
+	scale := d.scale + o.scale
+	if scale > maxDecimalScale {
+		v = roundQuo(v, pow10(scale-maxDecimalScale), mode)
+		scale = maxDecimalScale
+	}
+	return decimalFromBig(v, scale)
+}
+
+func (d Decimal) div(o Decimal, r DecimalRounding) (Decimal, error) {
+	if o.coef == 0 {
+		return Decimal{}, errors.New("divide by zero")
+	}
+
+	if r.Scale < 0 || r.Scale > maxDecimalScale {
+		return Decimal{}, fmt.Errorf("decimal scale out of range: %d", r.Scale)
+	}
+
+	// d / o = (d.coef / o.coef) * 10^(o.scale - d.scale),
+	// shift the numerator (or the denominator) to get the quotient in r.Scale
+	var (
+		num   = big.NewInt(d.coef)
+		den   = big.NewInt(o.coef)
+		shift = r.Scale + o.scale - d.scale
+	)
+	if shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+	return decimalFromBig(roundQuo(num, den, r.Mode), r.Scale)
+}
+
+func (d Decimal) mod(o Decimal) (Decimal, error) {
+	if o.coef == 0 {
+		return Decimal{}, errors.New("divide by zero")
+	}
+	scale := maxInt32(d.scale, o.scale)
+	return decimalFromBig(new(big.Int).Rem(d.rescale(scale), o.rescale(scale)), scale)
+}
+
+// round rounds the decimal to the scale, the floor and ceil are rounded
+// towards negative infinity and positive infinity when floor or ceil is true
+func (d Decimal) round(scale int32, mode RoundingMode, floor, ceil bool) Decimal {
+	if d.scale <= scale {
+		return d
+	}
+
+	switch {
+	case floor && d.coef < 0, ceil && d.coef > 0:
+		mode = RoundUp
+	case floor, ceil:
+		mode = RoundDown
+	}
+
+	// the rounded coefficient is not greater than the original one, so it won't overflow
+	v := roundQuo(big.NewInt(d.coef), pow10(d.scale-scale), mode)
+	return Decimal{coef: v.Int64(), scale: scale}
+}
+
+// roundQuo returns the quotient num / den rounded by the rounding mode
+func roundQuo(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+
+	var up bool
+	switch mode {
+	case RoundUp:
+		up = true
+	case RoundHalfUp, RoundHalfEven:
+		// compare the remainder with the half of the denominator
+		c := new(big.Int).Lsh(r.Abs(r), 1).Cmp(new(big.Int).Abs(den))
+		up = c > 0 || (c == 0 && (mode == RoundHalfUp || q.Bit(0) == 1))
+	}
+
+	if up {
+		if (num.Sign() < 0) != (den.Sign() < 0) {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+func decimalFromBig(v *big.Int, scale int32) (Decimal, error) {
+	if !v.IsInt64() {
+		return Decimal{}, errDecimalOverflow
+	}
+	return Decimal{coef: v.Int64(), scale: scale}, nil
+}
+
+// toDecimal converts the value to decimal, the int64 value is promoted to decimal
+func toDecimal(v Value) (Decimal, bool) {
+	switch n := v.(type) {
+	case Decimal:
+		return n, true
+	case int64:
+		return Decimal{coef: n}, true
+	}
+	return Decimal{}, false
+}
+
+func isDecimal(v Value) bool {
+	_, ok := v.(Decimal)
+	return ok
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func maxInt32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,81 @@
+package eval
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// durationUnits are the units of the duration literals, from the largest to the smallest
+var durationUnits = [...]struct {
+	name byte
+	secs int64
+}{{'d', 24 * 3600}, {'h', 3600}, {'m', 60}, {'s', 1}}
+
+// parseDuration parses the duration literal into seconds, e.g. 7d, 36h, 15m, 1h30m, -90s.
+// The units are d (days), h (hours), m (minutes) and s (seconds), they should be in this order
+func parseDuration(s string) (int64, error) {
+	d := strings.TrimPrefix(s, "-")
+	if d == "" {
+		return 0, &strconv.NumError{Func: "parseDuration", Num: s, Err: strconv.ErrSyntax}
+	}
+
+	var (
+		secs int64
+		unit = 0 // the index of the next allowed unit
+	)
+	for len(d) > 0 {
+		i := 0
+		for i < len(d) && d[i] >= '0' && d[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(d) {
+			return 0, &strconv.NumError{Func: "parseDuration", Num: s, Err: strconv.ErrSyntax}
+		}
+
+		j := unit
+		for j < len(durationUnits) && durationUnits[j].name != d[i] {
+			j++
+		}
+		if j == len(durationUnits) {
+			return 0, &strconv.NumError{Func: "parseDuration", Num: s, Err: strconv.ErrSyntax}
+		}
+
+		n, err := strconv.ParseInt(d[:i], 10, 64)
+		if err != nil || n > (math.MaxInt64-secs)/durationUnits[j].secs {
+			return 0, &strconv.NumError{Func: "parseDuration", Num: s, Err: strconv.ErrRange}
+		}
+		secs += n * durationUnits[j].secs
+		d, unit = d[i+1:], j+1
+	}
+
+	if len(s) != len(d) && s[0] == '-' {
+		return -secs, nil
+	}
+	return secs, nil
+}
+
+// formatDuration formats the seconds as a duration literal, e.g. 129600 is 1d12h, -90 is -1m30s
+func formatDuration(secs int64) string {
+	if secs == 0 {
+		return "0s"
+	}
+
+	var (
+		sb strings.Builder
+		n  = uint64(secs)
+	)
+	if secs < 0 {
+		sb.WriteByte('-')
+		n = -n
+	}
+
+	for _, u := range durationUnits {
+		if q := n / uint64(u.secs); q > 0 {
+			sb.WriteString(strconv.FormatUint(q, 10))
+			sb.WriteByte(u.name)
+			n %= uint64(u.secs)
+		}
+	}
+	return sb.String()
+}
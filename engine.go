@@ -3,6 +3,9 @@ package eval
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
+	"time"
 )
 
 type (
@@ -14,27 +17,54 @@ type (
 type Ctx struct {
 	VariableFetcher
 	Ctx context.Context
+	// Clock is the current time of the evaluation, the wall clock is used if it is nil
+	Clock Clock
+
+	// fields caches the values of the nested variables, e.g. address and address.country
+	fields map[string]Value
+}
+
+func (c *Ctx) cacheField(name string, v Value) {
+	if c.fields == nil {
+		c.fields = make(map[string]Value)
+	}
+	c.fields[name] = v
+}
+
+// currentTime returns the current time of the clock, the ctx is nil in the constant folding
+func (c *Ctx) currentTime() time.Time {
+	if c == nil || c.Clock == nil {
+		return time.Now()
+	}
+	return c.Clock.Now()
 }
 
 const (
 	// node types flag
-	nodeTypeMask = uint8(0b00000111)
+	nodeTypeMask = uint8(0b00001111)
 	constant     = uint8(0b00000001)
 	variable     = uint8(0b00000010)
 	operator     = uint8(0b00000011)
 	fastOperator = uint8(0b00000100)
 	cond         = uint8(0b00000101)
+	local        = uint8(0b00000110)
 	event        = uint8(0b00000111)
+	scope        = uint8(0b00001000)
+	loopHead     = uint8(0b00001001)
+	loopNext     = uint8(0b00001010)
+	multiCond    = uint8(0b00001011)
+	jumpTable    = uint8(0b00001100)
+	probe        = uint8(0b00001101)
 
 	// short circuit flag
-	scMask    = uint8(0b00011000)
-	scIfFalse = uint8(0b00001000)
-	scIfTrue  = uint8(0b00010000)
+	scMask    = uint8(0b00110000)
+	scIfFalse = uint8(0b00010000)
+	scIfTrue  = uint8(0b00100000)
 
 	// parent bool op flag
-	parentOpMask = uint8(0b01100000)
-	andOp        = uint8(0b00100000)
-	orOp         = uint8(0b01000000)
+	parentOpMask = uint8(0b11000000)
+	andOp        = uint8(0b01000000)
+	orOp         = uint8(0b10000000)
 )
 
 type node struct {
@@ -45,6 +75,9 @@ type node struct {
 	varKey   VariableKey
 	value    Value
 	operator Operator
+
+	// duration marks the constant of the duration literal (e.g. 7d), it is dumped as the literal instead of the seconds
+	duration bool
 }
 
 func (n *node) getNodeType() uint8 {
@@ -116,13 +149,14 @@ func (e *Expr) Eval(ctx *Ctx) (res Value, err error) {
 	case m <= 16:
 		os = make([]Value, 16)
 	default:
-		os = make([]Value, size)
+		os = make([]Value, m)
 	}
 
 	var (
 		params []Value
 		param2 [2]Value
 		curt   *node
+		more   bool
 	)
 
 	for i := int16(0); i < size; i++ {
@@ -133,7 +167,7 @@ func (e *Expr) Eval(ctx *Ctx) (res Value, err error) {
 			child := nodes[i]
 			res = child.value
 			if child.flag&nodeTypeMask == variable {
-				res, err = ctx.Get(child.varKey, res.(string))
+				res, err = fetchVariable(ctx, child)
 				if err != nil {
 					return
 				}
@@ -144,7 +178,7 @@ func (e *Expr) Eval(ctx *Ctx) (res Value, err error) {
 			child = nodes[i]
 			res = child.value
 			if child.flag&nodeTypeMask == variable {
-				res, err = ctx.Get(child.varKey, res.(string))
+				res, err = fetchVariable(ctx, child)
 				if err != nil {
 					return
 				}
@@ -155,12 +189,53 @@ func (e *Expr) Eval(ctx *Ctx) (res Value, err error) {
 				return
 			}
 		case variable:
-			res, err = ctx.Get(curt.varKey, curt.value.(string))
+			res, err = fetchVariable(ctx, curt)
 			if err != nil {
 				return
 			}
+		case probe:
+			// the fetch error of the probed variable means the variable is missing
+			res, err = fetchVariable(ctx, curt)
+			if err != nil {
+				res, err = missing, nil
+			}
 		case constant:
 			res = curt.value
+		case local:
+			res = os[curt.value.(*binding).slot]
+		case scope:
+			// drop the local variables, keep the result of the scope body
+			res, osTop = os[osTop], curt.osTop-1
+		case loopHead:
+			osTop = pushLoopFrame(curt, os, osTop)
+			more, err = iterate(curt, os)
+			if err != nil {
+				return
+			}
+			if !more {
+				// the collection is empty, jump to the loop next node
+				osTop, i = curt.osTop-frameElem, curt.scIdx
+			}
+			continue
+		case loopNext:
+			more, err = iterate(curt, os)
+			if err != nil {
+				return
+			}
+			if more {
+				// jump back to the loop head node, execute the loop body again
+				osTop, i = curt.osTop, curt.scIdx
+			} else {
+				osTop = curt.osTop - frameElem
+			}
+			continue
+		case multiCond:
+			// the result of the executed branch is the result of cond or switch
+			res, osTop = os[osTop], osTop-1
+		case jumpTable:
+			// pop the switch subject, jump to the selected branch
+			osTop, i = curt.osTop, curt.value.(*switchTable).jump(os[osTop])
+			continue
 		case operator:
 			cCnt := int16(curt.childCnt)
 			osTop = osTop - cCnt
@@ -225,13 +300,14 @@ func (e *Expr) TryEval(ctx *Ctx) (res Value, err error) {
 	case m <= 16:
 		os = make([]Value, 16)
 	default:
-		os = make([]Value, size)
+		os = make([]Value, m)
 	}
 
 	var (
 		param  []Value
 		param2 [2]Value
 		curt   *node
+		more   bool
 	)
 
 	for i := int16(0); i < size; i++ {
@@ -256,8 +332,40 @@ func (e *Expr) TryEval(ctx *Ctx) (res Value, err error) {
 			if err != nil {
 				return
 			}
+		case probe:
+			res = probeVariableValueProxy(ctx, curt)
 		case constant:
 			res = curt.value
+		case local:
+			res = os[curt.value.(*binding).slot]
+		case scope:
+			res, osTop = os[osTop], curt.osTop-1
+		case loopHead:
+			osTop = pushLoopFrame(curt, os, osTop)
+			more, err = iterate(curt, os)
+			if err != nil {
+				return
+			}
+			if !more {
+				osTop, i = curt.osTop-frameElem, curt.scIdx
+			}
+			continue
+		case loopNext:
+			more, err = iterate(curt, os)
+			if err != nil {
+				return
+			}
+			if more {
+				osTop, i = curt.osTop, curt.scIdx
+			} else {
+				osTop = curt.osTop - frameElem
+			}
+			continue
+		case multiCond:
+			res, osTop = os[osTop], osTop-1
+		case jumpTable:
+			osTop, i = curt.osTop, curt.value.(*switchTable).jump(os[osTop])
+			continue
 		case operator:
 			cCnt := int16(curt.childCnt)
 			osTop = osTop - cCnt
@@ -310,6 +418,331 @@ func (e *Expr) TryEval(ctx *Ctx) (res Value, err error) {
 	return os[0], nil
 }
 
+// The loop keywords (e.g. any, all) keep their states in a frame of the operand stack.
+// The frame starts from the collection, which is the result of the first child of the loop.
+// The result of the loop body is pushed right after the frame,
+// and the result of the loop is placed at the frame bottom when the loop finished.
+const (
+	frameColl = iota // the collection
+	frameAcc         // the accumulator
+	frameIdx         // the index of the current element
+	frameElem        // the current element
+	frameSize
+)
+
+// pushLoopFrame pushes the initial states of the loop frame, and returns the new top of the operand stack
+func pushLoopFrame(n *node, os []Value, osTop int16) int16 {
+	for osTop < n.osTop {
+		osTop++
+		os[osTop] = nil
+	}
+	os[n.osTop-frameElem+frameIdx] = -1
+	return osTop
+}
+
+// iterate consumes the result of the loop body and moves the loop frame to the next element.
+// It returns false when the loop is finished, the result of the loop is placed at the frame bottom
+func iterate(n *node, os []Value) (bool, error) {
+	var (
+		kw    = n.value.(*keywordInfo).keyword
+		frame = os[n.osTop-frameElem:]
+		idx   = frame[frameIdx].(int)
+	)
+
+	if idx == -1 {
+		// initialize the accumulator
+		switch kw {
+		case keywordMap:
+			frame[frameAcc] = &listBuilder{}
+		case keywordFilter:
+			// the filtered list keeps the type of the collection
+			frame[frameAcc] = &listBuilder{elemType: listElemType(frame[frameColl])}
+		}
+	} else {
+		res := frame[frameSize]
+		switch kw {
+		case keywordAny, keywordAll:
+			b, ok := res.(bool)
+			if !ok {
+				return false, fmt.Errorf("%s body returns a non bool result: [%v]", kw, res)
+			}
+			// any returns true when the first true result is found,
+			// all returns false when the first false result is found
+			if b == (kw == keywordAny) {
+				frame[frameColl] = b
+				return false, nil
+			}
+		case keywordMap:
+			frame[frameAcc].(*listBuilder).append(res)
+		case keywordReduce:
+			frame[frameAcc] = res
+		case keywordFilter:
+			b, ok := res.(bool)
+			if !ok {
+				return false, fmt.Errorf("%s body returns a non bool result: [%v]", kw, res)
+			}
+			if b {
+				frame[frameAcc].(*listBuilder).append(frame[frameElem])
+			}
+		}
+	}
+
+	size, ok := listLen(frame[frameColl])
+	if !ok {
+		return false, ParamTypeError(string(kw), typeList, frame[frameColl])
+	}
+
+	if idx++; idx < size {
+		frame[frameIdx], frame[frameElem] = idx, listElem(frame[frameColl], idx)
+		return true, nil
+	}
+
+	switch kw {
+	case keywordAny:
+		frame[frameColl] = false
+	case keywordAll:
+		frame[frameColl] = true
+	case keywordMap, keywordFilter:
+		frame[frameColl] = frame[frameAcc].(*listBuilder).build()
+	case keywordReduce:
+		frame[frameColl] = frame[frameAcc]
+	}
+	return false, nil
+}
+
+// listBuilder builds the result lists of the map and filter keywords.
+// The element type is inferred from the appended elements, the result list is
+// []int64, []float64 or []string when all the elements are of the same type, otherwise it is []Value
+type listBuilder struct {
+	elemType string
+	ints     []int64
+	floats   []float64
+	strs     []string
+	vals     []Value
+}
+
+func (b *listBuilder) append(v Value) {
+	if b.elemType == "" {
+		b.elemType = valueType(v)
+	}
+
+	switch b.elemType {
+	case typeInt:
+		if i, ok := v.(int64); ok {
+			b.ints = append(b.ints, i)
+			return
+		}
+	case typeFloat:
+		if f, ok := v.(float64); ok {
+			b.floats = append(b.floats, f)
+			return
+		}
+	case typeStr:
+		if s, ok := v.(string); ok {
+			b.strs = append(b.strs, s)
+			return
+		}
+	}
+
+	if b.elemType != typeList {
+		// the element type mismatched, convert the list to []Value
+		b.vals = make([]Value, 0, len(b.ints)+len(b.floats)+len(b.strs)+1)
+		for _, i := range b.ints {
+			b.vals = append(b.vals, i)
+		}
+		for _, f := range b.floats {
+			b.vals = append(b.vals, f)
+		}
+		for _, s := range b.strs {
+			b.vals = append(b.vals, s)
+		}
+		b.elemType, b.ints, b.floats, b.strs = typeList, nil, nil, nil
+	}
+	b.vals = append(b.vals, v)
+}
+
+func (b *listBuilder) build() Value {
+	switch b.elemType {
+	case typeInt:
+		if b.ints == nil {
+			return []int64{}
+		}
+		return b.ints
+	case typeFloat:
+		if b.floats == nil {
+			return []float64{}
+		}
+		return b.floats
+	case typeList:
+		if b.vals == nil {
+			return []Value{}
+		}
+		return b.vals
+	default:
+		// the empty list is a string list
+		if b.strs == nil {
+			return []string{}
+		}
+		return b.strs
+	}
+}
+
+// literalElemType returns the element type of the list or map literal, the integers are promoted
+// to floats when mixed with floats. It returns "" when the elements are of different types
+func literalElemType(vals []Value) string {
+	var typ string
+	for _, v := range vals {
+		var t string
+		switch v.(type) {
+		case int64:
+			t = typeInt
+		case float64:
+			t = typeFloat
+		case string:
+			t = typeStr
+		default:
+			return ""
+		}
+
+		switch {
+		case typ == "" || typ == t:
+			typ = t
+		case typ != typeStr && t != typeStr:
+			typ = typeFloat
+		default:
+			return ""
+		}
+	}
+	return typ
+}
+
+// listLiteral builds the constant list of the list literal, it is []int64, []float64 or []string
+// when all the elements are of the same type, otherwise it is []Value. The empty list is a string list
+func listLiteral(vals []Value) Value {
+	switch literalElemType(vals) {
+	case typeInt:
+		ints := make([]int64, len(vals))
+		for i, v := range vals {
+			ints[i] = v.(int64)
+		}
+		return ints
+	case typeFloat:
+		floats := make([]float64, len(vals))
+		for i, v := range vals {
+			floats[i], _ = toFloat(v)
+		}
+		return floats
+	case typeStr:
+		strs := make([]string, len(vals))
+		for i, v := range vals {
+			strs[i] = v.(string)
+		}
+		return strs
+	}
+
+	if len(vals) == 0 {
+		return []string{}
+	}
+	return vals
+}
+
+// mapLiteral builds the map of the map literal, it is map[string]int64, map[string]float64 or map[string]string
+// when all the values are of the same type, otherwise it is map[string]Value
+func mapLiteral(keys []string, vals []Value) (Value, error) {
+	seen := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		if _, exist := seen[k]; exist {
+			return nil, fmt.Errorf("duplicate map key %s", strconv.Quote(k))
+		}
+		seen[k] = struct{}{}
+	}
+
+	switch literalElemType(vals) {
+	case typeInt:
+		m := make(map[string]int64, len(keys))
+		for i, k := range keys {
+			m[k] = vals[i].(int64)
+		}
+		return m, nil
+	case typeFloat:
+		m := make(map[string]float64, len(keys))
+		for i, k := range keys {
+			m[k], _ = toFloat(vals[i])
+		}
+		return m, nil
+	case typeStr:
+		m := make(map[string]string, len(keys))
+		for i, k := range keys {
+			m[k] = vals[i].(string)
+		}
+		return m, nil
+	}
+
+	m := make(map[string]Value, len(keys))
+	for i, k := range keys {
+		m[k] = vals[i]
+	}
+	return m, nil
+}
+
+func valueType(v Value) string {
+	switch v.(type) {
+	case int64:
+		return typeInt
+	case float64:
+		return typeFloat
+	case string:
+		return typeStr
+	default:
+		return typeList
+	}
+}
+
+func listElemType(coll Value) string {
+	switch coll.(type) {
+	case []int64:
+		return typeInt
+	case []float64:
+		return typeFloat
+	case []string:
+		return typeStr
+	default:
+		return ""
+	}
+}
+
+func listLen(coll Value) (int, bool) {
+	switch l := coll.(type) {
+	case []int64:
+		return len(l), true
+	case []float64:
+		return len(l), true
+	case []string:
+		return len(l), true
+	case []Value:
+		return len(l), true
+	case []interface{}:
+		return len(l), true
+	}
+	return 0, false
+}
+
+func listElem(coll Value, i int) Value {
+	switch l := coll.(type) {
+	case []int64:
+		return l[i]
+	case []float64:
+		return l[i]
+	case []string:
+		return l[i]
+	case []Value:
+		return unifyType(l[i])
+	case []interface{}:
+		return unifyType(l[i])
+	}
+	return nil
+}
+
 func matchesShortCircuit(res Value, n *node) bool {
 	switch n.flag & parentOpMask {
 	case andOp:
@@ -317,7 +750,8 @@ func matchesShortCircuit(res Value, n *node) bool {
 	case orOp:
 		return res == true
 	default:
-		return res == DNE
+		// the DNE of the probed variable is handled by the special forms
+		return res == DNE && n.flag&nodeTypeMask != probe
 	}
 }
 
@@ -327,7 +761,7 @@ func executeOperatorProxy(ctx *Ctx, n *node, params []Value) (Value, error) {
 		return false, nil
 	case isOrOpNode(n) && contains(params, true):
 		return true, nil
-	case contains(params, DNE):
+	case contains(params, DNE) && !isSpecialFormNode(n):
 		return DNE, nil
 	}
 	return n.operator(ctx, params)
@@ -342,17 +776,33 @@ func getNodeValueProxy(ctx *Ctx, n *node) (res Value, err error) {
 	return
 }
 
-func fetchVariableValueProxy(ctx *Ctx, n *node) (Value, error) {
-	var (
-		varKey = n.varKey
-		strKey = n.value.(string)
-	)
+// fetchVariable fetches the value of the variable (or probe) node,
+// the node operator fetches the nested field in the nested field mode, e.g. address.country
+func fetchVariable(ctx *Ctx, n *node) (Value, error) {
+	if n.operator != nil {
+		return n.operator(ctx, nil)
+	}
+	return ctx.Get(n.varKey, n.value.(string))
+}
 
-	if !ctx.Cached(varKey, strKey) {
+func fetchVariableValueProxy(ctx *Ctx, n *node) (Value, error) {
+	varKey, strKey := n.varKey, n.value.(string)
+	// the nested field is available when its root variable is cached
+	if !ctx.Cached(varKey, strKey) && (n.operator == nil || !ctx.Cached(varKey, fieldRoot(strKey))) {
 		return DNE, nil
 	}
 
-	return ctx.Get(varKey, strKey)
+	return fetchVariable(ctx, n)
+}
+
+// probeVariableValueProxy returns DNE when the variable is not cached,
+// and returns the missing value when the variable can not be fetched
+func probeVariableValueProxy(ctx *Ctx, n *node) Value {
+	res, err := fetchVariableValueProxy(ctx, n)
+	if err != nil {
+		return missing
+	}
+	return res
 }
 
 type EventType string
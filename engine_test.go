@@ -6,6 +6,7 @@ import (
 	"math"
 	"math/rand"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -442,6 +443,415 @@ func TestExpr_Eval(t *testing.T) {
 				"s_78":  int64(78),
 			},
 		},
+		{
+			want: true,
+			s: `
+(let
+  ((age_s
+     (- now_ts registered_time)))
+  (and
+    (> age_s 10)
+    (< age_s 100)))`,
+			valMap: map[string]interface{}{
+				"now_ts":          int64(1000),
+				"registered_time": int64(950),
+			},
+		},
+		{
+			want:          int64(13),
+			optimizeLevel: disable,
+			s: `
+(let
+  ((a
+     (if (> v 2) v 0))
+   (b (* a 2)))
+  (let
+    ((a (+ a 1)))
+    (+ a b)))`,
+			valMap: map[string]interface{}{
+				"v": int64(4),
+			},
+		},
+		{
+			want:          false,
+			optimizeLevel: onlyFast,
+			s: `
+(or
+  (let ((a v)) (and (> a 3) (< a 3)))
+  (let ((b (- v 1))) (= b v)))`,
+			valMap: map[string]interface{}{
+				"v": int64(4),
+			},
+		},
+		{
+			want: true,
+			s: `
+(and
+  (any x order_amounts (> x 1000))
+  (all t user_tags (!= t "banned")))`,
+			valMap: map[string]interface{}{
+				"order_amounts": []int64{12, 3000, 45},
+				"user_tags":     []string{"vip", "new"},
+			},
+		},
+		{
+			want:          false,
+			optimizeLevel: disable,
+			s: `
+(or
+  (any x empty (> x 1))
+  (not (all x empty (> x 1)))
+  (all t user_tags (!= t "banned")))`,
+			valMap: map[string]interface{}{
+				"empty":     []int64{},
+				"user_tags": []string{"vip", "banned"},
+			},
+		},
+		{
+			want:          true,
+			optimizeLevel: onlyFast,
+			s: `
+(let ((limit 10))
+  (all x interests
+    (any y interests
+      (and (= x y) (< limit 100)))))`,
+			valMap: map[string]interface{}{
+				"interests": []Value{"music", int64(1), true},
+			},
+		},
+		{
+			want:          int64(2),
+			optimizeLevel: disable,
+			s: `
+(+ 1
+  (if
+    (any x order_amounts
+      (and (> x 10) (< x 20))) 1 0))`,
+			valMap: map[string]interface{}{
+				"order_amounts": []int64{5, 15, 25},
+			},
+		},
+		{
+			want: true,
+			s: `
+(overlap
+  (map t user_tags (if (= t "v") "vip" t))
+  ("vip" "gold"))`,
+			valMap: map[string]interface{}{
+				"user_tags": []string{"new", "v"},
+			},
+		},
+		{
+			want: []int64{3, 5},
+			s:    `(filter a amounts (> a 0))`,
+			valMap: map[string]interface{}{
+				"amounts": []int64{-1, 3, 0, 5},
+			},
+		},
+		{
+			want:          []int64{},
+			optimizeLevel: disable,
+			s:             `(filter a amounts (> a 10))`,
+			valMap: map[string]interface{}{
+				"amounts": []int64{-1, 3, 0, 5},
+			},
+		},
+		{
+			want:          []string{},
+			optimizeLevel: disable,
+			s:             `(map a amounts (* a 2))`,
+			valMap: map[string]interface{}{
+				"amounts": []int64{},
+			},
+		},
+		{
+			want:          true,
+			optimizeLevel: onlyFast,
+			s: `
+(let ((doubled (map a amounts (* a 2))))
+  (and
+    (in 10 doubled)
+    (not (in 5 doubled))))`,
+			valMap: map[string]interface{}{
+				"amounts": []int64{1, 5, 3},
+			},
+		},
+		{
+			want:          []Value{int64(1), "a"},
+			optimizeLevel: disable,
+			s:             `(map x mixed (if (= x 1) 1 "a"))`,
+			valMap: map[string]interface{}{
+				"mixed": []int64{1, 2},
+			},
+		},
+		{
+			want:          []string{"music"},
+			optimizeLevel: disable,
+			s:             `(filter x interests (= x "music"))`,
+			valMap: map[string]interface{}{
+				"interests": []Value{"music", int64(1), true},
+			},
+		},
+		{
+			want:          []int64{4},
+			optimizeLevel: disable,
+			s: `
+(map x
+  (filter y amounts (> y 1))
+  (* x x))`,
+			valMap: map[string]interface{}{
+				"amounts": []int64{1, 2},
+			},
+		},
+		{
+			want: int64(60),
+			s:    `(reduce acc 0 x amounts (+ acc x))`,
+			valMap: map[string]interface{}{
+				"amounts": []int64{10, 20, 30},
+			},
+		},
+		{
+			want:          int64(7),
+			optimizeLevel: disable,
+			s:             `(reduce acc 7 x amounts (+ acc x))`,
+			valMap: map[string]interface{}{
+				"amounts": []int64{},
+			},
+		},
+		{
+			want:          true,
+			optimizeLevel: onlyFast,
+			s: `
+(let ((total (reduce acc 0 x amounts (+ acc x))))
+  (and
+    (> total 50)
+    (reduce ok true a amounts (and ok (> a 0)))))`,
+			valMap: map[string]interface{}{
+				"amounts": []int64{10, 20, 30},
+			},
+		},
+		{
+			want: true,
+			s:    `(in country (collect "US" home_country (if vip "UK" "CN")))`,
+			valMap: map[string]interface{}{
+				"country":      "UK",
+				"home_country": "FR",
+				"vip":          true,
+			},
+		},
+		{
+			want:          []Value{int64(1), "a", true},
+			optimizeLevel: disable,
+			s:             `(collect 1 s (> 2 1))`,
+			valMap: map[string]interface{}{
+				"s": "a",
+			},
+		},
+		{
+			want:          []string{},
+			optimizeLevel: disable,
+			s:             `(collect)`,
+		},
+		{
+			want:          int64(12),
+			optimizeLevel: disable,
+			s: `
+(reduce acc 0 x (collect a b)
+  (reduce inner acc y (collect x x) (+ inner y)))`,
+			valMap: map[string]interface{}{
+				"a": int64(2),
+				"b": int64(4),
+			},
+		},
+		{
+			want: int64(20),
+			s: `
+(cond
+  ((> amount 1000) 30)
+  ((> amount 500) 20)
+  (else 0))`,
+			valMap: map[string]interface{}{
+				"amount": int64(700),
+			},
+		},
+		{
+			want:          int64(0),
+			optimizeLevel: disable,
+			s: `
+(cond
+  ((> amount 1000) 30)
+  ((> amount 500) 20)
+  (else 0))`,
+			valMap: map[string]interface{}{
+				"amount": int64(100),
+			},
+		},
+		{
+			want: int64(30),
+			s:    `(switch tier ("gold" 30) ("silver" 20) (default 0))`,
+			valMap: map[string]interface{}{
+				"tier": "gold",
+			},
+		},
+		{
+			// the integral float and decimal subjects match the int cases, the same as eq
+			want: true,
+			s:    `(and (= (switch score (1 "one") (default "other")) "one") (= (switch 2.00d (2 "two") (default "other")) "two") (= (switch 1.5 (1 "one") (default "other")) "other"))`,
+			valMap: map[string]interface{}{
+				"score": 1.0,
+			},
+		},
+		{
+			want:          int64(0),
+			optimizeLevel: disable,
+			s:             `(switch tier ("gold" 30) ("silver" 20) (default 0))`,
+			valMap: map[string]interface{}{
+				"tier": "bronze",
+			},
+		},
+		{
+			want:          int64(8),
+			optimizeLevel: onlyFast,
+			s: `
+(+ 1
+  (switch level
+    (1 (if vip 2 3))
+    (2 (cond (vip 4) (else 5)))
+    (default 6))
+  (switch vip (true 3) (false 4) (default 0)))`,
+			valMap: map[string]interface{}{
+				"level": int64(2),
+				"vip":   true,
+			},
+		},
+		{
+			want:          false,
+			optimizeLevel: disable,
+			s: `
+(and
+  (cond
+    ((= tier "gold") true)
+    (else (> amount 1000)))
+  (switch tier ("gold" true) (default (> 1 (/ 1 0)))))`,
+			valMap: map[string]interface{}{
+				"tier":   "silver",
+				"amount": int64(700),
+			},
+		},
+		{
+			want:          int64(1),
+			optimizeLevel: disable,
+			s:             `(switch tags ("a" 2) (default 1))`,
+			valMap: map[string]interface{}{
+				"tags": []string{"a"},
+			},
+		},
+		{
+			want: true,
+			s: `
+(and
+  (> risk_score 0.82)
+  (< (* rate amount) 150.5)
+  (= (/ amount 2.0) 50))`,
+			valMap: map[string]interface{}{
+				"risk_score": 0.9,
+				"rate":       float32(1.5),
+				"amount":     int64(100),
+			},
+		},
+		{
+			want: 0.75,
+			s:    `(- 1 (* 0.5 0.5))`,
+		},
+		{
+			want:          true,
+			optimizeLevel: disable,
+			s: `
+(and
+  (in 0.5 (0.25 0.5 1))
+  (overlap scores (1 2))
+  (between score 0 1))`,
+			valMap: map[string]interface{}{
+				"scores": []float32{0.5, 2},
+				"score":  0.5,
+			},
+		},
+		{
+			want:          []float64{1.5, 3},
+			optimizeLevel: disable,
+			s:             `(map x (1 2) (* x 1.5))`,
+		},
+		{
+			want: true,
+			s: `
+(and
+  (= (* price qty) 37.50d)
+  (between (- balance price) 0 (decimal "100"))
+  (>= discount 0.1d))`,
+			valMap: map[string]interface{}{
+				"price":    mustDecimal(1250, 2),
+				"qty":      3,
+				"balance":  mustDecimal(10000, 2),
+				"discount": func() *Decimal { d := mustDecimal(15, 2); return &d }(),
+			},
+		},
+		{
+			want:          mustDecimal(1250, 2), // the fractional literal with the d suffix is a decimal
+			optimizeLevel: disable,
+			s:             `(+ 12.50d 0)`,
+		},
+		{
+			want: mustDecimal(250000000, 8),
+			s:    `(/ 10.00d 4)`,
+		},
+		{
+			want:          true,
+			optimizeLevel: disable,
+			s: `
+(and
+  (= (+ 0xFF 0o17 0b1010 1_000) 1280)
+  (in 0x10 (0.5 16))
+  (in 16 (0.5 0x10))
+  (< 1_000_000 1e9))`,
+		},
+		{
+			want:          true,
+			optimizeLevel: disable,
+			s: `
+(and
+  (= (band 1003 1e3) 1000)
+  (> 1500000000000000001 15e17)
+  (= (+ 1e3 1) 1001))`,
+		},
+		{
+			want: true,
+			s: `
+(and
+  (starts_with (lower email) "admin@")
+  (ends_with email ".COM")
+  (contains (join (split tags ";") ",") "b,c")
+  (= (len (trim name)) 5)
+  (= (substr name (index_of name "B") 3) "Bob"))`,
+			valMap: map[string]interface{}{
+				"email": "Admin@Example.COM",
+				"tags":  "a;b;c",
+				"name":  "  Bob12 ",
+			},
+		},
+		{
+			want:          "John Doe",
+			optimizeLevel: disable,
+			s:             `(concat (upper (substr first 0 1)) (substr first 1) " " (replace last "_" ""))`,
+			valMap: map[string]interface{}{
+				"first": "john",
+				"last":  "D_o_e",
+			},
+		},
+		{
+			want:          mustDecimal(-1995, 3),
+			optimizeLevel: disable,
+			s:             `(- 0.005d (decimal "2"))`,
+		},
 	}
 
 	for _, c := range cs {
@@ -523,6 +933,13 @@ func TestEval_Infix(t *testing.T) {
 				"b": 2,
 			},
 		},
+		{
+			expr: `risk_score * 2 > 1.5 && risk_score <= 0.9`,
+			want: true,
+			vals: map[string]interface{}{
+				"risk_score": 0.9,
+			},
+		},
 		{
 			expr: `a + b + mod(7, 3)`,
 			want: int64(4),
@@ -552,28 +969,99 @@ func TestEval_Infix(t *testing.T) {
 			},
 		},
 		{
-			expr: `if(a > 0, a, 0 - a)`,
-			want: int64(32),
+			expr: `flags band 0x14 == 0x14 && flags has_bits 0b100`,
+			want: true,
 			vals: map[string]interface{}{
-				"a": 32,
+				"flags": 0x16,
 			},
 		},
 		{
-			expr: `if(a > 0, a, 0 - a)`,
-			want: int64(232),
+			expr: `1 << 4 bor 1 + 2`,
+			want: int64(19),
+		},
+		{
+			expr: `bnot a band 0xFF`,
+			want: int64(0xF0),
 			vals: map[string]interface{}{
-				"a": -232,
+				"a": 0x0F,
 			},
 		},
 		{
-			expr: `if(in(a, ["aa" "bb" "cc"]), add(b, c, d, e), mul(b, c, d, e))`,
-			want: int64(56),
+			expr: `band(flags, 0xF0) >> 4 bxor 1`,
+			want: int64(11),
 			vals: map[string]interface{}{
-				"a": "cc",
-				"b": 11,
-				"c": 17,
-				"d": 23,
-				"e": 5,
+				"flags": 0xAB,
+			},
+		},
+		{
+			expr: `a[0] + a[i + 1] * m["k"] == 16 && nested["l"][1] == "y"`,
+			want: true,
+			vals: map[string]interface{}{
+				"a": []int{1, 2, 3},
+				"i": 1,
+				"m": map[string]int64{"k": 5},
+				"nested": map[string]interface{}{
+					"l": []interface{}{"x", "y"},
+				},
+			},
+		},
+		{
+			expr: `in(first(l), [1 2]) && (l)[1] == 2 && 2 * pair(s, "b")[0] == 4`,
+			want: true,
+			vals: map[string]interface{}{
+				"l": []int{1, 2},
+				"s": "a",
+				"pair": func(_ *Ctx, params []Value) (Value, error) {
+					return []int64{int64(len(params))}, nil
+				},
+			},
+		},
+		{
+			expr: `xs[0] in [1 2] && !in(xs[1], [1 2]) && [[1 2] [3]][1][0] in [3] && x band (xs)[1] == 1`,
+			want: true,
+			vals: map[string]interface{}{
+				"xs": []int{1, 3},
+				"x":  5,
+			},
+		},
+		{
+			expr: `band(a[1], 0xF) == 0x2`,
+			want: true,
+			vals: map[string]interface{}{
+				"a": []int{0x11, 0x12},
+			},
+		},
+		{
+			expr: `min(100, clicks * 3) + clamp(round(score, 1), 0, 5)`,
+			want: 94.5,
+			vals: map[string]interface{}{
+				"clicks": 30,
+				"score":  4.46,
+			},
+		},
+		{
+			expr: `if(a > 0, a, 0 - a)`,
+			want: int64(32),
+			vals: map[string]interface{}{
+				"a": 32,
+			},
+		},
+		{
+			expr: `if(a > 0, a, 0 - a)`,
+			want: int64(232),
+			vals: map[string]interface{}{
+				"a": -232,
+			},
+		},
+		{
+			expr: `if(in(a, ["aa" "bb" "cc"]), add(b, c, d, e), mul(b, c, d, e))`,
+			want: int64(56),
+			vals: map[string]interface{}{
+				"a": "cc",
+				"b": 11,
+				"c": 17,
+				"d": 23,
+				"e": 5,
 			},
 		},
 		{
@@ -590,84 +1078,863 @@ func TestEval_Infix(t *testing.T) {
 	}
 	for _, c := range testCases {
 		t.Run(c.expr, func(t *testing.T) {
-			got, err := Eval(c.expr, c.vals,
-				Optimizations(false),
-				EnableInfixNotation,
-				RegVarAndOp(c.vals))
-
-			if len(c.errMsg) != 0 {
-				assertErrStrContains(t, err, c.errMsg)
-				return
+			got, err := Eval(c.expr, c.vals,
+				Optimizations(false),
+				EnableInfixNotation,
+				RegVarAndOp(c.vals))
+
+			if len(c.errMsg) != 0 {
+				assertErrStrContains(t, err, c.errMsg)
+				return
+			}
+
+			assertNil(t, err)
+			assertEquals(t, got, c.want)
+		})
+	}
+}
+
+func TestEval_AllowUnknownVariables(t *testing.T) {
+	testCases := []struct {
+		cc     *Config
+		expr   string
+		want   Value
+		errMsg string
+		vals   map[string]interface{}
+	}{
+		{
+			expr:   `(< age 18)`,
+			errMsg: "unknown token error",
+		},
+		{
+			want: false,
+			expr: `(< age 18)`,
+			cc:   NewConfig(EnableUndefinedVariable),
+			vals: map[string]interface{}{
+				"age": int64(20),
+			},
+		},
+		{
+			expr:   `(< not_exist_key 18)`,
+			cc:     NewConfig(EnableUndefinedVariable),
+			errMsg: "variableKey not exist",
+		},
+		{
+			expr: `
+(-
+ (+ 1
+   (- 2 v3) (/ 6 3) 4)
+ (* 5 -6 7)
+)`,
+			errMsg: "unknown token error",
+		},
+		{
+			want: int64(216),
+			expr: `
+(-
+ (+ 1
+   (- 2 v3) (/ 6 3) 4)
+ (* 5 -6 7)
+)`,
+			cc: NewConfig(EnableUndefinedVariable),
+			vals: map[string]interface{}{
+				"v3": int64(3),
+			},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			got, err := Eval(c.expr, c.vals, ExtendConf(c.cc))
+
+			if len(c.errMsg) != 0 {
+				assertErrStrContains(t, err, c.errMsg)
+				return
+			}
+
+			assertNil(t, err)
+			assertEquals(t, got, c.want)
+		})
+	}
+}
+
+func TestEval_LoopErrors(t *testing.T) {
+	testCases := []struct {
+		expr   string
+		errMsg string
+		vals   map[string]interface{}
+	}{
+		{
+			expr:   `(any x v (> x 1))`,
+			errMsg: "unexpected param type, operator: any, expected: list",
+			vals: map[string]interface{}{
+				"v": 1,
+			},
+		},
+		{
+			expr:   `(all x (1 2 3) x)`,
+			errMsg: "all body returns a non bool result",
+		},
+		{
+			expr:   `(filter x ("a" "b") 1)`,
+			errMsg: "filter body returns a non bool result",
+		},
+		{
+			expr:   `(map x "abc" x)`,
+			errMsg: "unexpected param type, operator: map, expected: list",
+		},
+		{
+			expr:   `(cond (1 2) (else 3))`,
+			errMsg: "condition node returns a non bool result",
+		},
+		{
+			expr:   `(reduce acc 0 x 1 (+ acc x))`,
+			errMsg: "unexpected param type, operator: reduce, expected: list",
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			_, err := Eval(c.expr, c.vals)
+			assertErrStrContains(t, err, c.errMsg)
+		})
+	}
+}
+
+func TestEval_Ordering(t *testing.T) {
+	type tier struct {
+		name  string
+		level int
+	}
+
+	cc := NewConfig()
+	assertNil(t, RegisterOrdering(cc, func(a, b tier) int { return a.level - b.level }))
+	assertErrStrContains(t, RegisterOrdering(cc, func(a, b tier) int { return 0 }), "ordering already exist")
+	assertErrStrContains(t, RegisterOrdering(cc, func(a, b string) int { return 0 }), "ordering already exist")
+	assertErrStrContains(t, RegisterOrdering(cc, func(a, b any) int { return 0 }), "interface type is not supported")
+	assertErrStrContains(t, RegisterOrdering(cc, func(a, b int32) int { return 0 }), "type int32 is not supported, its values are converted to int64")
+	assertErrStrContains(t, RegisterOrdering(cc, func(a, b uint) int { return 0 }), "type uint is not supported")
+	assertErrStrContains(t, RegisterOrdering(cc, func(a, b []int) int { return 0 }), "converted to []int64")
+	assertErrStrContains(t, RegisterOrdering(cc, func(a, b *Decimal) int { return 0 }), "type *eval.Decimal is not supported")
+
+	testCases := []struct {
+		expr    string
+		options []Option
+		vals    map[string]interface{}
+		want    Value
+		errMsg  string
+	}{
+		{
+			expr: `(and (< name "m") (between locale "en" "es"))`,
+			vals: map[string]interface{}{"name": "alice", "locale": "en-GB"},
+			want: true,
+		},
+		{
+			expr: `(< name "m")`,
+			vals: map[string]interface{}{"name": "Zoe"},
+			want: true,
+		},
+		{
+			expr:    `(< name "m")`,
+			options: []Option{StringCollation(CollationCaseInsensitive)},
+			vals:    map[string]interface{}{"name": "Zoe"},
+			want:    false,
+		},
+		{
+			expr:    `(and (<= name "ALICE") (>= name "alice"))`,
+			options: []Option{StringCollation(CollationCaseInsensitive)},
+			vals:    map[string]interface{}{"name": "Alice"},
+			want:    true,
+		},
+		{
+			expr: `(and (> user gold) (between user silver gold))`,
+			vals: map[string]interface{}{
+				"user":   tier{name: "platinum", level: 3},
+				"silver": tier{name: "silver", level: 1},
+				"gold":   tier{name: "gold", level: 2},
+			},
+			want: false,
+		},
+		{
+			expr: `(>= user silver)`,
+			vals: map[string]interface{}{
+				"user":   tier{name: "platinum", level: 3},
+				"silver": tier{name: "silver", level: 1},
+			},
+			want: true,
+		},
+		{
+			expr: `(> user 1)`,
+			vals: map[string]interface{}{
+				"user": tier{name: "platinum", level: 3},
+			},
+			errMsg: "unexpected param type",
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			options := append([]Option{ExtendConf(cc), RegVarAndOp(c.vals)}, c.options...)
+			res, err := Eval(c.expr, c.vals, options...)
+			if c.errMsg != "" {
+				assertErrStrContains(t, err, c.errMsg)
+				return
+			}
+			assertNil(t, err)
+			assertEquals(t, res, c.want)
+		})
+	}
+}
+
+func TestEval_Regex(t *testing.T) {
+	const pattern = `^[a-z]+@corp\.com$`
+	vals := map[string]interface{}{
+		"email":   "alice@corp.com",
+		"pattern": `^[a-z]+@corp\.org$`,
+	}
+
+	// the constant pattern is precompiled, so it is not cached
+	res, err := Eval("(matches email `"+pattern+"`)", vals)
+	assertNil(t, err)
+	assertEquals(t, res, true)
+	_, exist := regexCache.patterns[pattern]
+	assertEquals(t, exist, false)
+
+	// the dynamic pattern is cached
+	res, err = Eval(`(matches email pattern)`, vals)
+	assertNil(t, err)
+	assertEquals(t, res, false)
+	_, exist = regexCache.patterns[vals["pattern"].(string)]
+	assertEquals(t, exist, true)
+
+	res, err = Eval(`concat(extract(email, "^(\\w+)@"), "@", find(email, "[a-z]+\\.com"))`, vals, EnableInfixNotation, RegVarAndOp(vals))
+	assertNil(t, err)
+	assertEquals(t, res, "alice@corp.com")
+
+	_, err = Eval(`(find email pattern)`, map[string]interface{}{"email": "a", "pattern": "a("})
+	assertErrStrContains(t, err, "missing closing )")
+
+	cache := &boundedRegexCache{size: 2, patterns: map[string]*regexp.Regexp{}}
+	for _, p := range []string{"a", "b", "c", "c"} {
+		_, err = cache.compile(p)
+		assertNil(t, err)
+	}
+	assertEquals(t, len(cache.patterns), 2)
+	assertNotNil(t, cache.patterns["c"])
+}
+
+func TestEval_DecimalRounding(t *testing.T) {
+	testCases := []struct {
+		expr string
+		mode RoundingMode
+		want Value
+	}{
+		{expr: `(/ 5.00d 2 2)`, mode: RoundHalfUp, want: mustDecimal(13, 1)},
+		{expr: `(/ 5.00d 2 2)`, mode: RoundHalfEven, want: mustDecimal(12, 1)},
+		{expr: `(/ -5.00d 2 2)`, mode: RoundHalfUp, want: mustDecimal(-13, 1)},
+		{expr: `(/ 1 0.3d)`, mode: RoundDown, want: mustDecimal(33, 1)},
+		{expr: `(/ 1 0.3d)`, mode: RoundUp, want: mustDecimal(34, 1)},
+		{expr: `(* 0.25d 0.5d)`, mode: RoundDown, want: mustDecimal(125, 3)},
+		{expr: `(/ 1 (decimal "0.3"))`, mode: RoundUp, want: mustDecimal(34, 1)},
+		{expr: `(round 2.25d)`, mode: RoundHalfEven, want: mustDecimal(2, 0)},
+		{expr: `(round 2.25d 1)`, mode: RoundHalfUp, want: mustDecimal(23, 1)},
+		{expr: `(round 2.25d 1)`, mode: RoundHalfEven, want: mustDecimal(22, 1)},
+		{expr: `(pow 1.05d 2)`, mode: RoundDown, want: mustDecimal(11025, 4)},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			// the constant folding should also round with the config
+			for _, enable := range []bool{true, false} {
+				res, err := Eval(c.expr, nil, RoundDecimal(1, c.mode), Optimizations(enable))
+				assertNil(t, err)
+				assertEquals(t, res, c.want)
+			}
+		})
+	}
+}
+
+func TestNewDecimal(t *testing.T) {
+	d, err := NewDecimal(1250, 2)
+	assertNil(t, err)
+	assertEquals(t, d.String(), "12.50")
+
+	_, err = NewDecimal(1, 19)
+	assertErrStrContains(t, err, "decimal scale out of range: 19")
+
+	_, err = NewDecimal(1, -1)
+	assertErrStrContains(t, err, "decimal scale out of range: -1")
+}
+
+func TestEval_Null(t *testing.T) {
+	vals := map[string]interface{}{
+		"nickname": nil,
+		"name":     "alice",
+		"balance":  (*Decimal)(nil),
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []Option
+		want   Value
+		errMsg string
+	}{
+		{expr: `(is_null nickname)`, want: true},
+		{expr: `(is_null name)`, want: false},
+		{expr: `(is_null balance)`, want: true},
+		{expr: `(if (is_null nickname) name nickname)`, want: "alice"},
+		{expr: `(upper nickname)`, errMsg: "got: null"},
+		{expr: `(= nickname null)`, want: true},
+		{expr: `(!= nickname name)`, want: true},
+		{expr: `(= balance 0)`, want: false},
+		{expr: `(= balance 0)`, opts: []Option{EnableStrictEquality}, want: false},
+		{expr: `nickname == null || name != null`, opts: []Option{EnableInfixNotation}, want: true},
+		{expr: `(= nickname null)`, opts: []Option{EnableStrictNull}, errMsg: "unexpected null param"},
+		{expr: `(!= name nickname)`, opts: []Option{EnableStrictNull}, errMsg: "unexpected null param"},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			for _, enable := range []bool{true, false} {
+				opts := append([]Option{RegVarAndOp(vals), Optimizations(enable)}, c.opts...)
+				res, err := Eval(c.expr, vals, opts...)
+				if c.errMsg != "" {
+					assertErrStrContains(t, err, c.errMsg)
+					continue
+				}
+				assertNil(t, err)
+				assertEquals(t, res, c.want)
+			}
+		})
+	}
+}
+
+func TestEval_Literals(t *testing.T) {
+	vals := map[string]interface{}{
+		"tier":    "silver",
+		"country": "CA",
+		"score":   7,
+	}
+
+	tiers := func(c *Config) {
+		c.ConstantMap["GOLD"] = "gold"
+		c.ConstantMap["SILVER"] = "silver"
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []Option
+		want   Value
+		errMsg string
+	}{
+		{expr: `(in tier (GOLD SILVER))`, want: true},
+		{expr: `(in tier (GOLD (upper tier)))`, want: false},
+		{expr: `(in true (true false))`, want: true},
+		{expr: `(in "x" ("x" 1))`, want: true},
+		{expr: `(in score (GOLD 7))`, want: true},
+		{expr: `(in (1 2) ((1 2) (3)))`, want: true},
+		{expr: `(overlap (1 "a") ("a"))`, want: true},
+		{expr: `(overlap (GOLD 7) ("x" (+ score 0)))`, want: true},
+		{expr: `(overlap (1 2) ((1 2) (3)))`, want: false},
+		{expr: `(overlap (true) (1 "a"))`, want: false},
+		{expr: `(get {"US": 10, "CA": 8} country)`, want: int64(8)},
+		{expr: `(get {"US": score, "CA": (+ score 1)} country)`, want: int64(8)},
+		{expr: `(get_or_null {"US": 10} country)`, want: nil},
+		{expr: `(nth (first ((true false) (1 "a"))) 1)`, want: false},
+		{expr: `(get (get {"a": {"b": (1 2.5)}} "a") "b")`, want: []float64{1, 2.5}},
+		{expr: `(get (dict "US" 10 country score) "CA")`, want: int64(7)},
+		{expr: `(get (dict "CA" 10 country score) "CA")`, errMsg: "duplicate map key"},
+		{expr: `in(tier, [GOLD SILVER])`, opts: []Option{EnableInfixNotation}, want: true},
+		{expr: `{"US": 10, "CA": score}[country] + [[1 2] [3]][1][0]`, opts: []Option{EnableInfixNotation}, want: int64(10)},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			for _, enable := range []bool{true, false} {
+				opts := append([]Option{RegVarAndOp(vals), tiers, Optimizations(enable)}, c.opts...)
+				res, err := Eval(c.expr, vals, opts...)
+				if c.errMsg != "" {
+					assertErrStrContains(t, err, c.errMsg)
+					continue
+				}
+				assertNil(t, err)
+				assertEquals(t, res, c.want)
+			}
+		})
+	}
+}
+
+func TestEval_Equality(t *testing.T) {
+	vals := map[string]interface{}{
+		"tags":   []string{"a", "b"},
+		"scores": []int{1, 2},
+		"limits": map[string]interface{}{"US": 10, "CA": uint8(8)},
+		"level":  int32(3),
+		"name":   "alice",
+	}
+
+	testCases := []struct {
+		expr   string
+		opts   []Option
+		want   Value
+		errMsg string
+	}{
+		{expr: `(= tags ("a" "b"))`, want: true},
+		{expr: `(!= tags ("b" "a"))`, want: true},
+		{expr: `(= scores (1 2))`, want: true},
+		{expr: `(= scores (1.0 2.0))`, want: true},
+		{expr: `(= limits {"CA": 8, "US": 10})`, want: true},
+		{expr: `(= level 3)`, want: true},
+		{expr: `(= tags name)`, want: false},
+		{expr: `(!= name 1)`, want: true},
+		{expr: `(= tags name)`, opts: []Option{EnableStrictEquality}, errMsg: "mismatched param types"},
+		{expr: `(!= name 1)`, opts: []Option{EnableStrictEquality}, errMsg: "mismatched param types"},
+		{expr: `(= tags ("a" 1))`, opts: []Option{EnableStrictEquality}, errMsg: "mismatched param types"},
+		{expr: `(= level 3.0)`, opts: []Option{EnableStrictEquality}, want: true},
+		{expr: `tags == ["a" "b"] && limits != {"US": 10}`, opts: []Option{EnableInfixNotation}, want: true},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			for _, enable := range []bool{true, false} {
+				opts := append([]Option{RegVarAndOp(vals), Optimizations(enable)}, c.opts...)
+				res, err := Eval(c.expr, vals, opts...)
+				if c.errMsg != "" {
+					assertErrStrContains(t, err, c.errMsg)
+					continue
+				}
+				assertNil(t, err)
+				assertEquals(t, res, c.want)
+			}
+		})
+	}
+}
+
+func TestEval_Duration(t *testing.T) {
+	now := time.Now().Unix()
+	vals := map[string]interface{}{
+		"created":  now - 3*24*3600,
+		"expires":  now + 2*3600,
+		"timeout":  90 * time.Second,
+		"birthday": "2000-02-29",
+	}
+
+	testCases := []struct {
+		expr string
+		opts []Option
+		want Value
+	}{
+		{expr: `(< (since created) 7d)`, want: true},
+		{expr: `(> (since created) 2d23h59m)`, want: true},
+		{expr: `(<= (until expires) 2h)`, want: true},
+		{expr: `(= timeout 1m30s)`, want: true},
+		{expr: `(= (* 7 24 3600) 7d)`, want: true},
+		{expr: `(add_duration (date birthday) 1d)`, want: time.Date(2000, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{expr: `(= (truncate_to_day (add_duration (date birthday) 36h)) (date "2000-03-01"))`, want: true},
+		{expr: `since(created) > 3d - 1m && until(expires) <= 120m`, opts: []Option{EnableInfixNotation}, want: true},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			for _, enable := range []bool{true, false} {
+				opts := append([]Option{RegVarAndOp(vals), Optimizations(enable)}, c.opts...)
+				res, err := Eval(c.expr, vals, opts...)
+				assertNil(t, err)
+				assertEquals(t, res, c.want)
+			}
+		})
+	}
+}
+
+func TestEval_Time(t *testing.T) {
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	vals := map[string]interface{}{
+		"tz":       "Asia/Tokyo",
+		"login":    time.Date(2022, 5, 6, 23, 30, 0, 123_456_789, time.UTC),
+		"birthday": "2000-02-29",
+		"login_ms": int64(1651879800_123),
+		"login_ts": int64(1651879800),
+	}
+
+	testCases := []struct {
+		expr string
+		opts []Option
+		want Value
+	}{
+		{expr: `(datetime "2022-05-06 23:30:00" "2006-01-02 15:04:05" tz)`, want: time.Date(2022, 5, 6, 23, 30, 0, 0, tokyo)},
+		{expr: `(in_tz login tz)`, want: time.Date(2022, 5, 7, 8, 30, 0, 123_456_789, tokyo)},
+		{expr: `(truncate_to_day (in_tz login tz))`, want: time.Date(2022, 5, 7, 0, 0, 0, 0, tokyo)},
+		{expr: `(truncate_to_day login)`, want: time.Date(2022, 5, 6, 0, 0, 0, 0, time.UTC)},
+		{expr: `(= (in_tz login tz) login)`, want: true},
+		{expr: `(< (datetime "2022-05-07 08:30:00" "2006-01-02 15:04:05" tz) login)`, want: true},
+		{expr: `(> (add_duration login 1s) login)`, want: true},
+		{expr: `(between login (date "2022-05-06") (date "2022-05-07"))`, want: true},
+		{expr: `(= (date birthday) (date "2000-02-29" "2006-01-02" "UTC"))`, want: true},
+		{expr: `in_tz(login, tz) > date("2022-05-06")`, opts: []Option{EnableInfixNotation}, want: true},
+		{expr: `(from_unix_ms login_ms)`, want: time.Date(2022, 5, 6, 23, 30, 0, 123_000_000, time.UTC)},
+		{expr: `(hour (from_unix_ms login_ms tz))`, want: int64(8)},
+		{expr: `(> (from_unix_ms login_ms) (date "2022-05-06"))`, want: true},
+		{expr: `(= (from_unix 1651879800) (datetime "2022-05-06 23:30:00"))`, want: true},
+
+		// the int64 times are the Unix seconds
+		{expr: `(> login_ts (date "2020-01-01"))`, want: true},
+		{expr: `(< (date "2020-01-01") login_ts)`, want: true},
+		{expr: `(> login login_ts)`, want: true}, // the nanoseconds of login
+		{expr: `(between login_ts (date "2022-05-06") (date "2022-05-07"))`, want: true},
+		{expr: `(between login login_ts (date "2022-05-07"))`, want: true},
+		{expr: `(= login_ts (datetime "2022-05-06 23:30:00"))`, want: true},
+		{expr: `(!= login login_ts)`, want: true},
+		{expr: `(- login (date "2022-05-06"))`, want: int64(84600)},
+		{expr: `(- (datetime "2022-05-07 00:00:00") login_ts)`, want: int64(1800)},
+		{expr: `(+ (date "2022-05-06") 3600)`, want: int64(1651798800)},
+
+		// compatibility mode
+		{expr: `(date birthday)`, opts: []Option{EnableUnixTime}, want: int64(951782400)},
+		{expr: `(datetime "2022-05-07 08:30:00" "2006-01-02 15:04:05" tz)`, opts: []Option{EnableUnixTime}, want: int64(1651879800)},
+		{expr: `(= login 1651879800)`, opts: []Option{EnableUnixTime}, want: true},
+		{expr: `(- login (date "2022-05-06"))`, opts: []Option{EnableUnixTime}, want: int64(84600)},
+		{expr: `(add_duration (date birthday) 1d)`, opts: []Option{EnableUnixTime}, want: int64(951868800)},
+		{expr: `(from_unix_ms login_ms)`, opts: []Option{EnableUnixTime}, want: int64(1651879800)},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			for _, enable := range []bool{true, false} {
+				opts := append([]Option{RegVarAndOp(vals), Optimizations(enable)}, c.opts...)
+				res, err := Eval(c.expr, vals, opts...)
+				assertNil(t, err)
+				assertEquals(t, res, c.want)
+			}
+		})
+	}
+}
+
+func TestEval_Schedule(t *testing.T) {
+	vals := map[string]interface{}{
+		"tz":       "Asia/Tokyo",
+		"order":    time.Date(2022, 12, 30, 1, 30, 0, 0, time.UTC), // Friday 10:30 in Tokyo
+		"schedule": "MON-FRI 09:00-17:00",
+	}
+
+	testCases := []struct {
+		expr string
+		opts []Option
+		want Value
+	}{
+		{expr: `(in_schedule order "MON-FRI 09:00-17:00" tz)`, want: true},
+		{expr: `(in_schedule order "MON-FRI 09:00-17:00")`, want: false},
+		{expr: `(in_schedule order schedule tz)`, want: true},
+		{expr: `(in_schedule order "DEC20-JAN05")`, want: true},
+		{expr: `(in_schedule (date "2023-01-02") "MON#1")`, want: true},
+		{expr: `(and (= (weekday order tz) 5) (= (hour order tz) 10) (= (minute order) 30))`, want: true},
+		{expr: `(= (day_of_year order) (- 365 1))`, want: true},
+		{expr: `(collect (year order) (month order) (day order))`, want: []int64{2022, 12, 30}},
+		{expr: `in_schedule(order, "SAT,SUN", tz) || hour(order, tz) >= 9`, opts: []Option{EnableInfixNotation}, want: true},
+		{expr: `(in_schedule order "FRI 09:00-17:00" tz)`, opts: []Option{EnableUnixTime}, want: true},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			for _, enable := range []bool{true, false} {
+				opts := append([]Option{RegVarAndOp(vals), Optimizations(enable)}, c.opts...)
+				res, err := Eval(c.expr, vals, opts...)
+				assertNil(t, err)
+				assertEquals(t, res, c.want)
+			}
+		})
+	}
+
+	// the invalid constant schedule is a compile error
+	_, err := Compile(NewConfig(RegVarAndOp(vals)), `(in_schedule order "MON-FRI 9:00-17:00")`)
+	assertErrStrContains(t, err, `invalid schedule "MON-FRI 9:00-17:00": invalid clock 9:00`)
+
+	// the invalid dynamic schedule is an execution error
+	_, err = Eval(`(in_schedule order schedule)`, map[string]interface{}{"order": 0, "schedule": "DEC32"})
+	assertErrStrContains(t, err, "invalid date DEC32")
+}
+
+func TestEval_Clock(t *testing.T) {
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	pinned := time.Date(2022, 5, 6, 23, 30, 0, 0, time.UTC)
+	vals := map[string]interface{}{
+		"tz":      "Asia/Tokyo",
+		"signup":  time.Date(2022, 4, 30, 0, 0, 0, 0, time.UTC),
+		"expires": pinned.Unix() + 3600,
+	}
+
+	testCases := []struct {
+		expr string
+		opts []Option
+		want Value
+	}{
+		{expr: `(now)`, want: pinned},
+		{expr: `(now tz)`, want: pinned.In(tokyo)},
+		{expr: `(today)`, want: time.Date(2022, 5, 6, 0, 0, 0, 0, time.UTC)},
+		{expr: `(today tz)`, want: time.Date(2022, 5, 7, 0, 0, 0, 0, tokyo)},
+		{expr: `(since signup)`, want: int64(6*24*3600 + 23*3600 + 30*60)},
+		{expr: `(until expires)`, want: int64(3600)},
+		{expr: `(in_schedule (now) "FRI 09:00-24:00")`, want: true},
+		{expr: `(let ((age (since signup))) (and (> age 6d) (< age 7d)))`, want: true},
+		{expr: `(now)`, opts: []Option{EnableUnixTime}, want: pinned.Unix()},
+		{expr: `(- (now) signup)`, opts: []Option{EnableUnixTime}, want: int64(6*24*3600 + 23*3600 + 30*60)},
+		{expr: `(< (since expires) (* 7 24 3600))`, want: true},
+		{expr: `(> (now) expires)`, opts: []Option{EnableUnixTime}, want: false},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			for _, enable := range []bool{true, false} {
+				conf := NewConfig(append([]Option{RegVarAndOp(vals), Optimizations(enable)}, c.opts...)...)
+				expr, err := Compile(conf, c.expr)
+				assertNil(t, err)
+
+				ctx := NewCtxFromVars(conf, vals)
+				ctx.Clock = FixedClock(pinned)
+				res, err := expr.Eval(ctx)
+				assertNil(t, err)
+				assertEquals(t, res, c.want)
 			}
+		})
+	}
 
-			assertNil(t, err)
-			assertEquals(t, got, c.want)
+	// the wall clock is used by default
+	res, err := Eval(`(since (now))`, nil)
+	assertNil(t, err)
+	assertEquals(t, res, int64(0))
+
+	// the time zone is the only optional param
+	_, err = Eval(`(now "UTC" "Asia/Tokyo")`, nil)
+	assertErrStrContains(t, err, "expected: 0 to 1, got: 2")
+}
+
+func TestEval_SemVer(t *testing.T) {
+	vals := map[string]interface{}{
+		"app_version": "3.1.0-beta.2+build.9",
+		"os_version":  SemVer{Major: 16, Minor: 4},
+		"constraint":  "^16.1",
+	}
+
+	testCases := []struct {
+		expr string
+		opts []Option
+		want Value
+	}{
+		{expr: `(version_satisfies app_version ">=2.3.0 <3.0.0 || ^3.1")`, want: false},
+		{expr: `(version_satisfies app_version ">=2.3.0 <3.0.0 || >=3.1.0-beta <4")`, want: true},
+		{expr: `(version_satisfies os_version constraint)`, want: true},
+		{expr: `(and (< (semver app_version) (semver "3.1.0-beta.11")) (< (semver "3.1.0-beta.11") (semver "3.1.0")))`, want: true},
+		{expr: `(= (semver app_version) (semver "3.1.0-beta.2"))`, want: true},
+		{expr: `(between os_version (semver "16.0.0") (semver "17.0.0-0"))`, want: true},
+		{expr: `(>= (to_version "3.1.0") (to_version "2.3.4"))`, want: true},
+		{expr: `version_satisfies(os_version, ">= 16.4.0") && semver(app_version) > semver("3.0.0")`, opts: []Option{EnableInfixNotation}, want: true},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			for _, enable := range []bool{true, false} {
+				opts := append([]Option{RegVarAndOp(vals), Optimizations(enable)}, c.opts...)
+				res, err := Eval(c.expr, vals, opts...)
+				assertNil(t, err)
+				assertEquals(t, res, c.want)
+			}
 		})
 	}
+
+	// the invalid constant constraint is a compile error
+	_, err := Compile(NewConfig(RegVarAndOp(vals)), `(version_satisfies app_version ">=2.3.0 <3.0")`)
+	assertNil(t, err)
+	_, err = Compile(NewConfig(RegVarAndOp(vals)), `(version_satisfies app_version ">=2.3.0 <3.0.0.1")`)
+	assertErrStrContains(t, err, `invalid version constraint ">=2.3.0 <3.0.0.1": invalid semver: 3.0.0.1`)
+
+	// the invalid dynamic constraint is an execution error
+	_, err = Eval(`(version_satisfies os_version constraint)`, map[string]interface{}{"os_version": "16.4.0", "constraint": "~>16"})
+	assertErrStrContains(t, err, "invalid semver: >16")
 }
 
-func TestEval_AllowUnknownVariables(t *testing.T) {
+func TestEval_SpecialForms(t *testing.T) {
+	vals := map[string]interface{}{
+		"name":     "alice",
+		"nickname": nil,
+		"age":      18,
+	}
+
 	testCases := []struct {
-		cc     *Config
 		expr   string
 		want   Value
 		errMsg string
-		vals   map[string]interface{}
 	}{
-		{
-			expr:   `(< age 18)`,
-			errMsg: "unknown token error",
-		},
-		{
-			want: false,
-			expr: `(< age 18)`,
-			cc:   NewConfig(EnableUndefinedVariable),
-			vals: map[string]interface{}{
-				"age": int64(20),
+		{expr: `(exists name)`, want: true},
+		{expr: `(exists nickname)`, want: true},
+		{expr: `(exists promo_code)`, want: false},
+		{expr: `(default promo_code "")`, want: ""},
+		{expr: `(default name "")`, want: "alice"},
+		{expr: `(default nickname name)`, want: "alice"},
+		{expr: `(coalesce promo_code nickname name "guest")`, want: "alice"},
+		{expr: `(coalesce promo_code nickname)`, want: nil},
+		{expr: `(coalesce null "guest")`, want: "guest"},
+		{expr: `(and (exists age) (>= (default age 0) 18))`, want: true},
+		{expr: `(let ((code (default promo_code "none"))) (concat "code: " code))`, want: "code: none"},
+		{expr: `(= promo_code "")`, errMsg: "variableKey not exist"},
+		{expr: `(exists "name")`, errMsg: "only accepts a variable"},
+		{expr: `(default promo_code)`, errMsg: "parameters count error"},
+		{expr: `(coalesce promo_code)`, errMsg: "parameters count error"},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			for _, enable := range []bool{true, false} {
+				res, err := Eval(c.expr, vals, EnableUndefinedVariable, Optimizations(enable))
+				if c.errMsg != "" {
+					assertErrStrContains(t, err, c.errMsg)
+					continue
+				}
+				assertNil(t, err)
+				assertEquals(t, res, c.want)
+			}
+		})
+	}
+
+	// the special forms can be called in infix notation
+	res, err := Eval(`default(promo_code, 0) + 1 == 1 && !exists(promo_code)`, vals,
+		EnableUndefinedVariable, EnableInfixNotation, RegVarAndOp(vals))
+	assertNil(t, err)
+	assertEquals(t, res, true)
+	res, err = Eval(`default(promo_code, 0) + 1 == 1 && !exists(promo_code)`, vals, EnableInfixNotation, RegVarAndOp(vals))
+	assertNil(t, err)
+	assertEquals(t, res, true)
+	_, err = Eval(`default(promo_code + 1, 0) == 1`, vals, EnableInfixNotation, RegVarAndOp(vals))
+	assertErrStrContains(t, err, "unknown token error")
+
+	// the default config, the unknown variables are only allowed as the params of the special forms
+	for expr, want := range map[string]Value{
+		`(exists promo_code)`:                   false,
+		`(exists nickname)`:                     true,
+		`(default promo_code "")`:               "",
+		`(coalesce promo_code nickname name)`:   "alice",
+		`(default (concat name promo_code) "")`: nil,
+	} {
+		res, err = Eval(expr, vals)
+		if want == nil {
+			assertErrStrContains(t, err, "unknown token error")
+			continue
+		}
+		assertNil(t, err)
+		assertEquals(t, res, want)
+	}
+
+	// the registered variables without values are missing
+	cc := NewConfig(RegVarAndOp(vals))
+	GetOrRegisterKey(cc, "promo_code")
+	for expr, want := range map[string]Value{
+		`(exists promo_code)`:     false,
+		`(exists nickname)`:       true,
+		`(default promo_code "")`: "",
+		`(= promo_code "")`:       nil,
+	} {
+		e, err := Compile(cc, expr)
+		assertNil(t, err)
+		res, err = e.Eval(NewCtxFromVars(cc, vals))
+		if want == nil {
+			assertErrStrContains(t, err, "variableKey not exist")
+			continue
+		}
+		assertNil(t, err)
+		assertEquals(t, res, want)
+	}
+}
+
+type countingFetcher struct {
+	MapVarFetcher
+	gets map[string]int
+}
+
+func (f *countingFetcher) Get(key VariableKey, strKey string) (Value, error) {
+	f.gets[strKey]++
+	return f.MapVarFetcher.Get(key, strKey)
+}
+
+func TestEval_NestedField(t *testing.T) {
+	type Address struct {
+		Country string
+		City    string `eval:"city_name"`
+		Zip     *int
+		secret  string
+	}
+
+	type User struct {
+		Name    string
+		Address *Address `eval:"address"`
+		Tags    map[string]int
+	}
+
+	zip := 94105
+	vals := map[string]interface{}{
+		"address": map[string]interface{}{
+			"country": "US",
+			"geo": map[string]interface{}{
+				"lat": 37.7,
 			},
 		},
-		{
-			expr:   `(< not_exist_key 18)`,
-			cc:     NewConfig(EnableUndefinedVariable),
-			errMsg: "variableKey not exist",
-		},
-		{
-			expr: `
-(-
- (+ 1
-   (- 2 v3) (/ 6 3) 4)
- (* 5 -6 7)
-)`,
-			errMsg: "unknown token error",
-		},
-		{
-			want: int64(216),
-			expr: `
-(-
- (+ 1
-   (- 2 v3) (/ 6 3) 4)
- (* 5 -6 7)
-)`,
-			cc: NewConfig(EnableUndefinedVariable),
-			vals: map[string]interface{}{
-				"v3": int64(3),
-			},
+		"user": &User{
+			Name:    "alice",
+			Address: &Address{Country: "US", City: "SF", Zip: &zip, secret: "x"},
+			Tags:    map[string]int{"vip": 1},
 		},
+		"guest":           User{Name: "bob"},
+		"address.country": "CA", // the registered dotted name is fetched as a whole
+	}
+
+	testCases := []struct {
+		expr   string
+		want   Value
+		errMsg string
+	}{
+		{expr: `(= address.geo.lat 37.7)`, want: true},
+		{expr: `(concat user.Name "@" user.address.city_name)`, want: "alice@SF"},
+		{expr: `(+ user.address.Zip user.Tags.vip)`, want: int64(94106)},
+		{expr: `(is_null guest.address.Country)`, want: true},
+		{expr: `(= address.country "CA")`, want: true},
+		{expr: `(exists address.state)`, want: false},
+		{expr: `(default user.address.state "CA")`, want: "CA"},
+		{expr: `(= address.state "CA")`, errMsg: "field not exist address.state"},
+		{expr: `(= user.address.secret "x")`, errMsg: "struct field not found secret"},
+		{expr: `(= user.address.City "SF")`, errMsg: "struct field not found City"},
+		{expr: `(= user.Name.first "a")`, errMsg: "unsupported type string"},
 	}
 
 	for _, c := range testCases {
 		t.Run(c.expr, func(t *testing.T) {
-			got, err := Eval(c.expr, c.vals, ExtendConf(c.cc))
-
-			if len(c.errMsg) != 0 {
-				assertErrStrContains(t, err, c.errMsg)
-				return
+			for _, enable := range []bool{true, false} {
+				res, err := Eval(c.expr, vals, RegVarAndOp(vals), EnableNestedField, Optimizations(enable))
+				if c.errMsg != "" {
+					assertErrStrContains(t, err, c.errMsg)
+					continue
+				}
+				assertNil(t, err)
+				assertEquals(t, res, c.want)
 			}
-
-			assertNil(t, err)
-			assertEquals(t, got, c.want)
 		})
 	}
+
+	// the dotted name is an undefined variable without the nested field mode
+	_, err := Eval(`(= user.Name "alice")`, vals, RegVarAndOp(vals))
+	assertErrStrContains(t, err, "unknown token error")
+
+	// the root variable is fetched once, the sibling paths reuse it
+	cc := NewConfig(EnableNestedField, RegVarAndOp(vals))
+	expr, err := Compile(cc, `(and (= user.address.Country "US") (= user.address.city_name "SF") (= user.Name "alice"))`)
+	assertNil(t, err)
+
+	fetcher := &countingFetcher{MapVarFetcher: NewMapVarFetcher(vals), gets: map[string]int{}}
+	res, err := expr.Eval(&Ctx{VariableFetcher: fetcher})
+	assertNil(t, err)
+	assertEquals(t, res, true)
+	assertEquals(t, fetcher.gets, map[string]int{"user": 1})
+
+	// the nested field is unknown when its root variable is not cached
+	res, err = expr.TryEval(&Ctx{VariableFetcher: NewMapVarFetcher(nil)})
+	assertNil(t, err)
+	assertEquals(t, res, DNE)
 }
 
 func TestExpr_TryEval(t *testing.T) {
@@ -1054,6 +2321,99 @@ func TestExpr_TryEval(t *testing.T) {
 				"F": false,
 			},
 		},
+		{
+			want:          DNE,
+			optimizeLevel: disable,
+			s:             `(let ((a dne)) (+ a 1))`,
+		},
+		{
+			want:          DNE,
+			optimizeLevel: disable,
+			s:             `(any x dne (> x 1))`,
+		},
+		{
+			want:          true,
+			optimizeLevel: disable,
+			s: `
+(or
+  (all x dne (> x 1))
+  (any x amounts (= x 3)))`,
+			valMap: map[string]interface{}{
+				"amounts": []int64{1, 2, 3},
+			},
+		},
+		{
+			want:          DNE,
+			optimizeLevel: disable,
+			s:             `(any x amounts (= x dne))`,
+			valMap: map[string]interface{}{
+				"amounts": []int64{1, 2, 3},
+			},
+		},
+		{
+			want:          DNE,
+			optimizeLevel: disable,
+			s:             `(in 1 (map x dne (+ x 1)))`,
+		},
+		{
+			want:          DNE,
+			optimizeLevel: disable,
+			s:             `(reduce acc 0 x amounts (+ acc dne))`,
+			valMap: map[string]interface{}{
+				"amounts": []int64{1, 2, 3},
+			},
+		},
+		{
+			want:          true,
+			optimizeLevel: disable,
+			s:             `(or (in 1 (collect dne 2)) (in 2 (collect 2 3)))`,
+		},
+		{
+			want:          DNE,
+			optimizeLevel: disable,
+			s:             `(cond ((> dne 1) 1) (else 0))`,
+		},
+		{
+			want:          DNE,
+			optimizeLevel: disable,
+			s:             `(switch v (1 dne) (default 0))`,
+			valMap: map[string]interface{}{
+				"v": int64(1),
+			},
+		},
+		{
+			want:          true,
+			optimizeLevel: disable,
+			s: `
+(or
+  (switch dne ("a" false) (default false))
+  (cond ((= v 1) (< v 2)) (else false)))`,
+			valMap: map[string]interface{}{
+				"v": int64(1),
+			},
+		},
+		{
+			want:          true,
+			optimizeLevel: disable,
+			s: `
+(or
+  (in 1 (filter x amounts (> x dne)))
+  (overlap (map x amounts (+ x 1)) (4)))`,
+			valMap: map[string]interface{}{
+				"amounts": []int64{1, 2, 3},
+			},
+		},
+		{
+			want:          false,
+			optimizeLevel: disable,
+			s: `
+(and
+  (let ((a dne)) (> a 1))
+  (let ((b v)) (< b 0)))`,
+			valMap: map[string]interface{}{
+				"v": int64(1),
+			},
+		},
 		{
 			want:          true,
 			optimizeLevel: all,
@@ -1074,6 +2434,35 @@ func TestExpr_TryEval(t *testing.T) {
 				"v_5": int64(5),
 			},
 		},
+		{
+			want: "bob",
+			s:    `(coalesce nickname dne "guest")`,
+			valMap: map[string]interface{}{
+				"nickname": "bob",
+			},
+		},
+		{
+			want: "guest",
+			s:    `(default nickname "guest")`,
+			valMap: map[string]interface{}{
+				"nickname": nil,
+			},
+		},
+		{
+			want: DNE,
+			s:    `(coalesce nickname dne "guest")`,
+			valMap: map[string]interface{}{
+				"nickname": nil,
+			},
+		},
+		{
+			want: DNE,
+			s:    `(exists dne)`,
+		},
+		{
+			want: false,
+			s:    `(and (exists dne) (= 1 2))`,
+		},
 	}
 
 	for _, c := range cs {
@@ -1398,6 +2787,45 @@ func TestReportEvent(t *testing.T) {
 	})
 }
 
+func TestReportEvent_Loop(t *testing.T) {
+	vals := map[string]interface{}{
+		"amounts": []int64{-1, 3, 5},
+	}
+
+	cc := NewConfig(EnableReportEvent, RegVarAndOp(vals))
+
+	e, err := Compile(cc, `(filter a amounts (> a 0))`)
+	assertNil(t, err)
+	e.EventChan = make(chan Event)
+
+	var params [][]Value
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+
+	go func() {
+		for ev := range e.EventChan {
+			if data, ok := ev.Data.(OpEventData); ok {
+				// the params share the operand stack, copy them
+				params = append(params, append([]Value(nil), data.Params...))
+			}
+		}
+		wg.Done()
+	}()
+
+	res, err := e.Eval(NewCtxFromVars(cc, vals))
+	close(e.EventChan)
+
+	wg.Wait()
+	assertNil(t, err)
+	assertEquals(t, res, []int64{3, 5})
+	// the loop body is executed once for each element
+	assertEquals(t, params, [][]Value{
+		{int64(-1), int64(0)},
+		{int64(3), int64(0)},
+		{int64(5), int64(0)},
+	})
+}
+
 func TestStatelessOperators(t *testing.T) {
 	cc := &Config{
 		OperatorMap: map[string]Operator{
@@ -1464,6 +2892,14 @@ func TestStatelessOperators(t *testing.T) {
 	assertEquals(t, len(expr.nodes), 3)
 }
 
+func mustDecimal(coef int64, scale int32) Decimal {
+	d, err := NewDecimal(coef, scale)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
 func assertEquals(t *testing.T, got, want any, msg ...any) {
 	if !reflect.DeepEqual(got, want) {
 		t.Fatalf("assertEquals failed, got: %+v, want: %+v, msg: %+v", got, want, msg)
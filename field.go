@@ -0,0 +1,173 @@
+package eval
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fieldPath is the dotted variable in the nested field mode, e.g. address.country.
+// The root variable (address) is fetched by the VariableFetcher, then the nested fields
+// of maps, structs and pointers are walked by the names after the dots
+type fieldPath struct {
+	name    string      // the dotted name, e.g. address.country
+	rootKey VariableKey // the key of the root variable
+	dots    []int       // the indexes of the dots in the name
+}
+
+func newFieldPath(name string, rootKey VariableKey) *fieldPath {
+	f := &fieldPath{name: name, rootKey: rootKey}
+	for i := 0; i < len(name); i++ {
+		if name[i] == '.' {
+			f.dots = append(f.dots, i)
+		}
+	}
+	return f
+}
+
+// fetch is the operator of the nested variable node. The values of the path prefixes are cached in the Ctx,
+// so the sibling paths (e.g. address.city and address.state) reuse the fetch of the parent
+func (f *fieldPath) fetch(ctx *Ctx, _ []Value) (Value, error) {
+	if v, exist := ctx.fields[f.name]; exist {
+		return v, nil
+	}
+
+	// walk from the longest cached prefix
+	var (
+		v Value
+		i = len(f.dots) - 1
+	)
+	for ; i >= 0; i-- {
+		if pv, exist := ctx.fields[f.name[:f.dots[i]]]; exist {
+			v = pv
+			break
+		}
+	}
+
+	if i < 0 {
+		root := f.name[:f.dots[0]]
+		rv, err := ctx.Get(f.rootKey, root)
+		if err != nil {
+			return nil, err
+		}
+		if rv == DNE {
+			return DNE, nil
+		}
+		v, i = rv, 0
+		ctx.cacheField(root, v)
+	}
+
+	for ; i < len(f.dots); i++ {
+		end := len(f.name)
+		if i+1 < len(f.dots) {
+			end = f.dots[i+1]
+		}
+
+		fv, err := fieldOf(v, f.name[f.dots[i]+1:end])
+		if err != nil {
+			return nil, fmt.Errorf("field not exist %s, %w", f.name[:end], err)
+		}
+		v = unifyType(indirect(fv))
+		ctx.cacheField(f.name[:end], v)
+	}
+	return v, nil
+}
+
+// fieldRoot returns the name of the root variable of the nested variable node
+func fieldRoot(name string) string {
+	if i := strings.IndexByte(name, '.'); i != -1 {
+		return name[:i]
+	}
+	return name
+}
+
+// fieldOf returns the field of the map or the struct, the pointers are dereferenced.
+// The field of null is null, e.g. address.country is null when address is null
+func fieldOf(v Value, name string) (Value, error) {
+	switch m := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		fv, exist := m[name]
+		if !exist {
+			return nil, fmt.Errorf("key not found %s", name)
+		}
+		return fv, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		fv := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		if !fv.IsValid() {
+			return nil, fmt.Errorf("key not found %s", name)
+		}
+		return fv.Interface(), nil
+	case reflect.Struct:
+		idx, exist := structFieldIndex(rv.Type(), name)
+		if !exist {
+			return nil, fmt.Errorf("struct field not found %s", name)
+		}
+		return rv.Field(idx).Interface(), nil
+	}
+	return nil, fmt.Errorf("unsupported type %T", v)
+}
+
+// indirect dereferences the pointer, the nil pointer is null
+func indirect(v Value) Value {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer {
+		return v
+	}
+
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	return rv.Interface()
+}
+
+// structFields caches the field indexes of the struct types, reflect.Type -> map[string]int
+var structFields sync.Map
+
+// structFieldIndex returns the index of the exported struct field, the field is
+// named by the eval tag (e.g. `eval:"country"`) or the field name, the tag "-" hides the field
+func structFieldIndex(typ reflect.Type, name string) (int, bool) {
+	fields, ok := structFields.Load(typ)
+	if !ok {
+		m := make(map[string]int)
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			switch tag := f.Tag.Get("eval"); tag {
+			case "-":
+			case "":
+				if _, exist := m[f.Name]; !exist {
+					m[f.Name] = i
+				}
+			default:
+				// the tag names take precedence over the field names
+				m[tag] = i
+			}
+		}
+		fields, _ = structFields.LoadOrStore(typ, m)
+	}
+
+	i, exist := fields.(map[string]int)[name]
+	return i, exist
+}
@@ -3,13 +3,20 @@ package eval
 import (
 	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
+// RegisterOperator registers the operator to the config, the registered operator shadows the builtin
+// operator of the same name (e.g. max), except the reserved ones which the compiler relies on (e.g. and)
 func RegisterOperator(cc *Config, name string, op Operator) error {
-	if _, exist := builtinOperators[name]; exist {
+	if reservedOperators[name] {
 		return fmt.Errorf("operator already exist %s", name)
 	}
 
@@ -35,6 +42,29 @@ var (
 		"/":   arithmetic{mode: div}.execute,
 		"%":   arithmetic{mode: mod}.execute,
 
+		// bitwise
+		"band":     bitwise{mode: band}.execute,
+		"bor":      bitwise{mode: bor}.execute,
+		"bxor":     bitwise{mode: bxor}.execute,
+		"bnot":     bitwiseNot,
+		"shl":      bitwise{mode: shl}.execute,
+		"shr":      bitwise{mode: shr}.execute,
+		"<<":       bitwise{mode: shl}.execute,
+		">>":       bitwise{mode: shr}.execute,
+		"has_bits": hasBits,
+
+		// math
+		"abs":   mathOperator{mode: absolute}.execute,
+		"min":   mathOperator{mode: minimum}.execute,
+		"max":   mathOperator{mode: maximum}.execute,
+		"pow":   mathOperator{mode: power}.execute,
+		"sqrt":  mathOperator{mode: squareRoot}.execute,
+		"round": mathOperator{mode: round}.execute,
+		"floor": mathOperator{mode: floor}.execute,
+		"ceil":  mathOperator{mode: ceil}.execute,
+		"clamp": mathOperator{mode: clamp}.execute,
+		"sign":  mathOperator{mode: sign}.execute,
+
 		// logic
 		"and": logic{mode: and}.execute,
 		"or":  logic{mode: or}.execute,
@@ -45,24 +75,63 @@ var (
 		"!":   logicNot,
 
 		// comparison
-		"eq":      comparisonEquals,
-		"ne":      comparisonNotEquals,
+		"eq":      equality{mode: equals}.execute,
+		"ne":      equality{mode: notEquals}.execute,
 		"gt":      comparison{mode: greater}.execute,
 		"lt":      comparison{mode: less}.execute,
 		"ge":      comparison{mode: greaterEquals}.execute,
 		"le":      comparison{mode: lessEquals}.execute,
-		"=":       comparisonEquals,
-		"!=":      comparisonNotEquals,
+		"=":       equality{mode: equals}.execute,
+		"!=":      equality{mode: notEquals}.execute,
 		">":       comparison{mode: greater}.execute,
 		"<":       comparison{mode: less}.execute,
 		">=":      comparison{mode: greaterEquals}.execute,
 		"<=":      comparison{mode: lessEquals}.execute,
-		"between": comparisonBetween,
+		"between": comparison{mode: between}.execute,
+
+		// null
+		"is_null": isNull,
 
 		// list
 		"in":      listIn,
 		"overlap": listOverlap,
 
+		// map
+		"dict": dict,
+
+		// element
+		"get":         element{mode: getElem}.execute,
+		"get_or_null": element{mode: getOrNull}.execute,
+		"nth":         element{mode: nthElem}.execute,
+		"first":       element{mode: firstElem}.execute,
+		"last":        element{mode: lastElem}.execute,
+
+		// string
+		"concat":      strConcat,
+		"len":         strLen,
+		"length":      strLen,
+		"lower":       strTransform("lower", strings.ToLower),
+		"to_lower":    strTransform("lower", strings.ToLower),
+		"upper":       strTransform("upper", strings.ToUpper),
+		"to_upper":    strTransform("upper", strings.ToUpper),
+		"trim":        strTrim,
+		"contains":    strPredicate("contains", strings.Contains),
+		"starts_with": strPredicate("starts_with", strings.HasPrefix),
+		"has_prefix":  strPredicate("starts_with", strings.HasPrefix),
+		"ends_with":   strPredicate("ends_with", strings.HasSuffix),
+		"has_suffix":  strPredicate("ends_with", strings.HasSuffix),
+		"substr":      strSubstr,
+		"substring":   strSubstr,
+		"index_of":    strIndexOf,
+		"replace":     strReplace,
+		"split":       strSplit,
+		"join":        strJoin,
+
+		// regex
+		"matches": regexOperator{mode: matches}.execute,
+		"find":    regexOperator{mode: find}.execute,
+		"extract": regexOperator{mode: extract}.execute,
+
 		// time
 		"date":        timeConvert{mode: date, layout: defaultDateLayout}.execute,
 		"datetime":    timeConvert{mode: datetime, layout: defaultDatetimeLayout}.execute,
@@ -74,31 +143,141 @@ var (
 		"td_time": timeConvert{mode: toDefaultTime, layout: defaultDatetimeLayout}.execute,
 		"td_date": timeConvert{mode: toDefaultDate, layout: defaultDateLayout}.execute,
 
+		"since":           timeArithmetic{mode: since}.execute,
+		"until":           timeArithmetic{mode: until}.execute,
+		"add_duration":    timeArithmetic{mode: addDuration}.execute,
+		"truncate_to_day": timeArithmetic{mode: truncateToDay}.execute,
+		"in_tz":           inTimeZone,
+		"from_unix":       unixConvert{mode: fromUnix}.execute,
+		"from_unix_ms":    unixConvert{mode: fromUnixMs}.execute,
+		"now":             clock{mode: now}.execute,
+		"today":           clock{mode: today}.execute,
+
+		"year":        calendar{mode: year}.execute,
+		"month":       calendar{mode: month}.execute,
+		"day":         calendar{mode: day}.execute,
+		"weekday":     calendar{mode: weekday}.execute,
+		"hour":        calendar{mode: hour}.execute,
+		"minute":      calendar{mode: minute}.execute,
+		"day_of_year": calendar{mode: dayOfYear}.execute,
+		"in_schedule": scheduleOperator{}.execute,
+
+		// decimal
+		"decimal": decimalConvert,
+
 		// version
 		"version":    versionConvert{mode: version, validLen: 3}.execute,
 		"t_version":  versionConvert{mode: toVersion, validLen: 3}.execute,
 		"to_version": versionConvert{mode: version, validLen: 3}.execute,
 
+		"semver":            semverConvert,
+		"to_semver":         semverConvert,
+		"version_satisfies": versionSatisfies{}.execute,
+
 		// infix notation patch
-		"==": comparisonEquals,
+		"==": equality{mode: equals}.execute,
 		"&&": logic{mode: and}.execute,
 		"||": logic{mode: or}.execute,
 	}
 
+	// reservedOperators are the builtin operators which can not be shadowed by the operators of the config,
+	// the compiler relies on their semantics, e.g. the short circuit of and, or
+	reservedOperators = map[string]bool{
+		"add": true, "sub": true, "mul": true, "div": true, "mod": true, "+": true, "-": true, "*": true, "/": true, "%": true,
+		"and": true, "or": true, "xor": true, "not": true, "&": true, "|": true, "!": true,
+		"eq": true, "ne": true, "gt": true, "lt": true, "ge": true, "le": true,
+		"=": true, "!=": true, ">": true, "<": true, ">=": true, "<=": true, "between": true,
+		"in": true, "overlap": true,
+		"date": true, "datetime": true, "to_date": true, "to_datetime": true,
+		"t_time": true, "t_date": true, "td_time": true, "td_date": true,
+		"version": true, "t_version": true, "to_version": true,
+		"==": true, "&&": true, "||": true,
+	}
+
+	// configurableOperators are the builtin operators which are bound with the config at compile time,
+	// e.g. the decimal division is rounded by Config.DecimalRounding
+	configurableOperators = map[string]func(cc *Config) Operator{
+		"eq":      configurableEquality(equals),
+		"ne":      configurableEquality(notEquals),
+		"=":       configurableEquality(equals),
+		"!=":      configurableEquality(notEquals),
+		"==":      configurableEquality(equals),
+		"mul":     roundingArithmetic(mul),
+		"div":     roundingArithmetic(div),
+		"*":       roundingArithmetic(mul),
+		"/":       roundingArithmetic(div),
+		"gt":      orderedComparison(greater),
+		"lt":      orderedComparison(less),
+		"ge":      orderedComparison(greaterEquals),
+		"le":      orderedComparison(lessEquals),
+		">":       orderedComparison(greater),
+		"<":       orderedComparison(less),
+		">=":      orderedComparison(greaterEquals),
+		"<=":      orderedComparison(lessEquals),
+		"between": orderedComparison(between),
+		"pow":     roundingMath(power),
+		"round":   roundingMath(round),
+
+		"date":         configurableTime(date, defaultDateLayout),
+		"datetime":     configurableTime(datetime, defaultDatetimeLayout),
+		"to_date":      configurableTime(date, defaultDateLayout),
+		"to_datetime":  configurableTime(datetime, defaultDatetimeLayout),
+		"t_time":       configurableTime(toTime, ""),
+		"t_date":       configurableTime(toDate, ""),
+		"td_time":      configurableTime(toDefaultTime, defaultDatetimeLayout),
+		"td_date":      configurableTime(toDefaultDate, defaultDateLayout),
+		"now":          configurableClock(now),
+		"today":        configurableClock(today),
+		"from_unix":    configurableUnix(fromUnix),
+		"from_unix_ms": configurableUnix(fromUnixMs),
+	}
+
+	// precompiledOperators are the builtin operators whose constant second param is precompiled at compile time,
+	// e.g. the regex pattern of (matches email ".*@corp\.com$"), the errors are reported as the compile errors
+	precompiledOperators = map[string]func(s string) (Operator, error){
+		"matches":           precompileRegex(matches),
+		"find":              precompileRegex(find),
+		"extract":           precompileRegex(extract),
+		"in_schedule":       precompileSchedule,
+		"version_satisfies": precompileVersionConstraint,
+	}
+
 	// Currently builtinOperators are all stateless functions,
 	// stateless functions will be used in optimizeConstantFolding,
 	// so please make sure when adding new operators into builtinStatelessOperations
 	builtinStatelessOperations = []string{
 		"add", "sub", "mul", "div", "mod", "+", "-", "*", "/", "%",
+		"band", "bor", "bxor", "bnot", "shl", "shr", "<<", ">>", "has_bits",
+		"abs", "min", "max", "pow", "sqrt", "round", "floor", "ceil", "clamp", "sign",
 		"and", "or", "xor", "not", "&", "|", "!",
 		"eq", "ne", "gt", "lt", "ge", "le", "=", "!=", ">", "<", ">=", "<=", "between",
+		"is_null",
 		"in", "overlap",
+		"dict",
+		"get", "get_or_null", "nth", "first", "last",
+		"concat", "len", "length", "lower", "to_lower", "upper", "to_upper", "trim",
+		"contains", "starts_with", "has_prefix", "ends_with", "has_suffix",
+		"substr", "substring", "index_of", "replace", "split", "join",
+		"matches", "find", "extract",
 		"date", "datetime", "to_date", "to_datetime", "t_time", "t_date", "td_time", "td_date",
-		"version", "t_version", "to_version",
+		"add_duration", "truncate_to_day", "in_tz", "from_unix", "from_unix_ms",
+		"year", "month", "day", "weekday", "hour", "minute", "day_of_year", "in_schedule",
+		"decimal",
+		"version", "t_version", "to_version", "semver", "to_semver", "version_satisfies",
 		"==", "&&", "||",
 	}
 )
 
+// isBuiltinOperator checks whether the operator of the name is the builtin one,
+// the operators of the config shadow the builtin operators except the reserved ones
+func isBuiltinOperator(cc *Config, name string) bool {
+	if _, exist := builtinOperators[name]; !exist {
+		return false
+	}
+	_, shadowed := cc.OperatorMap[name]
+	return !shadowed || reservedOperators[name]
+}
+
 type mode int
 
 const (
@@ -109,6 +288,25 @@ const (
 	div
 	mod
 
+	// bitwise
+	band
+	bor
+	bxor
+	shl
+	shr
+
+	// math
+	absolute
+	minimum
+	maximum
+	power
+	squareRoot
+	round
+	floor
+	ceil
+	clamp
+	sign
+
 	// logical
 	and
 	or
@@ -128,6 +326,18 @@ const (
 	in
 	overlap
 
+	// element
+	getElem
+	getOrNull
+	nthElem
+	firstElem
+	lastElem
+
+	// regex
+	matches
+	find
+	extract
+
 	// time
 	date
 	datetime
@@ -135,6 +345,23 @@ const (
 	toDate
 	toDefaultTime
 	toDefaultDate
+	since
+	until
+	addDuration
+	truncateToDay
+	fromUnix
+	fromUnixMs
+	now
+	today
+
+	// calendar
+	year
+	month
+	day
+	weekday
+	hour
+	minute
+	dayOfYear
 
 	version
 	toVersion
@@ -148,6 +375,25 @@ var modeNames = [...]string{
 	div: "div",
 	mod: "mod",
 
+	// bitwise
+	band: "band",
+	bor:  "bor",
+	bxor: "bxor",
+	shl:  "shl",
+	shr:  "shr",
+
+	// math
+	absolute:   "abs",
+	minimum:    "min",
+	maximum:    "max",
+	power:      "pow",
+	squareRoot: "sqrt",
+	round:      "round",
+	floor:      "floor",
+	ceil:       "ceil",
+	clamp:      "clamp",
+	sign:       "sign",
+
 	// logical
 	and: "and",
 	or:  "or",
@@ -167,6 +413,18 @@ var modeNames = [...]string{
 	in:      "in",
 	overlap: "overlap",
 
+	// element
+	getElem:   "get",
+	getOrNull: "get_or_null",
+	nthElem:   "nth",
+	firstElem: "first",
+	lastElem:  "last",
+
+	// regex
+	matches: "matches",
+	find:    "find",
+	extract: "extract",
+
 	// time
 	date:          "date",
 	datetime:      "datetime",
@@ -174,6 +432,23 @@ var modeNames = [...]string{
 	toDate:        "t_date",
 	toDefaultTime: "td_time",
 	toDefaultDate: "td_date",
+	since:         "since",
+	until:         "until",
+	addDuration:   "add_duration",
+	truncateToDay: "truncate_to_day",
+	fromUnix:      "from_unix",
+	fromUnixMs:    "from_unix_ms",
+	now:           "now",
+	today:         "today",
+
+	// calendar
+	year:      "year",
+	month:     "month",
+	day:       "day",
+	weekday:   "weekday",
+	hour:      "hour",
+	minute:    "minute",
+	dayOfYear: "day_of_year",
 
 	// version
 	version:   "version",
@@ -181,18 +456,29 @@ var modeNames = [...]string{
 }
 
 const (
-	typeBool    = "bool"
-	typeInt     = "int64"
-	typeStr     = "string"
-	typeIntList = "[]int64"
-	typeStrList = "[]string"
+	typeBool      = "bool"
+	typeInt       = "int64"
+	typeFloat     = "float64"
+	typeDecimal   = "decimal"
+	typeOrdered   = "ordered"
+	typeNumber    = "number"
+	typeStr       = "string"
+	typeIntList   = "[]int64"
+	typeFloatList = "[]float64"
+	typeStrList   = "[]string"
+	typeList      = "list"
+	typeMap       = "map"
+	typeTime      = "time"
+	typeSemVer    = "semver"
 )
 
 type arithmetic struct {
 	mode mode
+	// rounding is the rounding of the decimal division, defaultDecimalRounding is used when it is nil
+	rounding *DecimalRounding
 }
 
-func (a arithmetic) execute(_ *Ctx, params []Value) (Value, error) {
+func (a arithmetic) execute(ctx *Ctx, params []Value) (Value, error) {
 	if len(params) < 2 {
 		return nil, errCnt2(a.mode, params)
 	}
@@ -201,7 +487,21 @@ func (a arithmetic) execute(_ *Ctx, params []Value) (Value, error) {
 	for i, p := range params {
 		v, ok := p.(int64)
 		if !ok {
-			return nil, errTypeInt(a.mode, p)
+			switch p.(type) {
+			case time.Time:
+				// the times are the Unix seconds in the addition and the subtraction,
+				// e.g. (- (now) signup_ts) is the seconds since signup_ts
+				if a.mode == add || a.mode == sub {
+					return a.execute(ctx, unixSeconds(params))
+				}
+			case float64:
+				// the result is float64 when there are float params
+				return a.executeFloat(params)
+			case Decimal:
+				// the result is decimal when there are decimal params
+				return a.executeDecimal(params)
+			}
+			return nil, errTypeNumber(a.mode, p)
 		}
 
 		if i == 0 {
@@ -232,221 +532,939 @@ func (a arithmetic) execute(_ *Ctx, params []Value) (Value, error) {
 	return res, nil
 }
 
-type logic struct {
+func roundingArithmetic(m mode) func(cc *Config) Operator {
+	return func(cc *Config) Operator {
+		return arithmetic{mode: m, rounding: cc.DecimalRounding}.execute
+	}
+}
+
+func (a arithmetic) executeFloat(params []Value) (Value, error) {
+	var res float64
+	for i, p := range params {
+		v, ok := toFloat(p)
+		if !ok {
+			return nil, errTypeNumber(a.mode, p)
+		}
+
+		if i == 0 {
+			res = v
+		} else {
+			switch a.mode {
+			case add:
+				res += v
+			case sub:
+				res -= v
+			case mul:
+				res *= v
+			case div:
+				if v == 0 {
+					return nil, OpExecError("div", errors.New("divide by zero"))
+				}
+				res /= v
+			case mod:
+				if v == 0 {
+					return nil, OpExecError("mod", errors.New("divide by zero"))
+				}
+				res = math.Mod(res, v)
+			default:
+				return 0, errInvalidMode(a.mode, "arithmetic")
+			}
+		}
+	}
+
+	if math.IsInf(res, 0) || math.IsNaN(res) {
+		return nil, OpExecError(modeNames[a.mode], fmt.Errorf("float overflow: %v", res))
+	}
+	return res, nil
+}
+
+func (a arithmetic) executeDecimal(params []Value) (Value, error) {
+	rounding := defaultDecimalRounding
+	if a.rounding != nil {
+		rounding = *a.rounding
+	}
+
+	var res Decimal
+	for i, p := range params {
+		v, ok := toDecimal(p)
+		if !ok {
+			return nil, ParamTypeError(modeNames[a.mode], typeDecimal, p)
+		}
+
+		if i == 0 {
+			res = v
+			continue
+		}
+
+		var err error
+		switch a.mode {
+		case add:
+			res, err = res.add(v)
+		case sub:
+			res, err = res.sub(v)
+		case mul:
+			res, err = res.mul(v, rounding.Mode)
+		case div:
+			res, err = res.div(v, rounding)
+		case mod:
+			res, err = res.mod(v)
+		default:
+			return 0, errInvalidMode(a.mode, "arithmetic")
+		}
+
+		if err != nil {
+			return nil, OpExecError(modeNames[a.mode], err)
+		}
+	}
+	return res, nil
+}
+
+// toFloat converts the number to float64, the int64 number is promoted to float64
+func toFloat(v Value) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// toFloatList converts the number list to []float64, the int64 numbers are promoted to float64
+func toFloatList(v Value) ([]float64, bool) {
+	switch l := v.(type) {
+	case []float64:
+		return l, true
+	case []int64:
+		res := make([]float64, len(l))
+		for i, n := range l {
+			res[i] = float64(n)
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+type bitwise struct {
 	mode mode
 }
 
-func (c logic) execute(_ *Ctx, params []Value) (Value, error) {
+func (b bitwise) execute(_ *Ctx, params []Value) (Value, error) {
 	if len(params) < 2 {
-		return nil, errCnt2(c.mode, params)
+		return nil, errCnt2(b.mode, params)
 	}
 
-	var res bool
+	var res int64
 	for i, p := range params {
-		v, ok := p.(bool)
+		v, ok := p.(int64)
 		if !ok {
-			return nil, errTypeBool(c.mode, p)
+			return nil, errTypeInt(b.mode, p)
 		}
+
 		if i == 0 {
 			res = v
 		} else {
-			switch c.mode {
-			case and:
-				res = res && v
-			case or:
-				res = res || v
-			case xor:
-				res = res != v
+			switch b.mode {
+			case band:
+				res &= v
+			case bor:
+				res |= v
+			case bxor:
+				res ^= v
+			case shl, shr:
+				if v < 0 {
+					return nil, OpExecError(modeNames[b.mode], fmt.Errorf("negative shift count: %d", v))
+				}
+				if b.mode == shl {
+					res <<= uint64(v)
+				} else {
+					res >>= uint64(v)
+				}
 			default:
-				return false, errInvalidMode(c.mode, "logic")
+				return 0, errInvalidMode(b.mode, "bitwise")
 			}
 		}
 	}
 	return res, nil
 }
 
-func logicNot(_ *Ctx, params []Value) (Value, error) {
-	const op = "not"
+func bitwiseNot(_ *Ctx, params []Value) (Value, error) {
+	const op = "bnot"
 	if len(params) != 1 {
 		return nil, ParamsCountError(op, 1, len(params))
 	}
-	v, ok := params[0].(bool)
+	v, ok := params[0].(int64)
 	if !ok {
-		return nil, ParamTypeError(op, typeBool, params[0])
+		return nil, ParamTypeError(op, typeInt, params[0])
 	}
-	return !v, nil
+	return ^v, nil
 }
 
-type comparison struct {
+// hasBits checks if all the bits of the mask are set, e.g. (has_bits user_flags 0x14)
+func hasBits(_ *Ctx, params []Value) (Value, error) {
+	const op = "has_bits"
+	v, mask, err := DestructParamsInt2(op, params)
+	if err != nil {
+		return nil, err
+	}
+	return v&mask == mask, nil
+}
+
+var errIntOverflow = errors.New("integer overflow")
+
+// mathOperator is the math functions, the int64 params are promoted to float64
+// when there are float params, or to Decimal when there are decimal params
+type mathOperator struct {
 	mode mode
+	// rounding is the rounding mode of the decimal pow and round, defaultDecimalRounding is used when it is nil
+	rounding *DecimalRounding
 }
 
-func (c comparison) execute(_ *Ctx, params []Value) (Value, error) {
-	if len(params) != 2 {
-		return nil, errCnt2(c.mode, params)
+func roundingMath(m mode) func(cc *Config) Operator {
+	return func(cc *Config) Operator {
+		return mathOperator{mode: m, rounding: cc.DecimalRounding}.execute
 	}
+}
 
-	i, ok := params[0].(int64)
-	if !ok {
-		return nil, errTypeInt(c.mode, params[0])
+func (m mathOperator) execute(_ *Ctx, params []Value) (Value, error) {
+	switch m.mode {
+	case minimum, maximum:
+		return m.extremum(params)
+	case clamp:
+		return m.clamp(params)
+	case power:
+		return m.pow(params)
+	case round, floor, ceil:
+		return m.round(params)
 	}
 
-	j, ok := params[1].(int64)
-	if !ok {
-		return nil, errTypeInt(c.mode, params[1])
+	op := modeNames[m.mode]
+	if len(params) != 1 {
+		return nil, ParamsCountError(op, 1, len(params))
 	}
 
-	switch c.mode {
-	case greater:
-		return i > j, nil
-	case less:
-		return i < j, nil
-	case greaterEquals:
-		return i >= j, nil
-	case lessEquals:
-		return i <= j, nil
+	switch x := params[0].(type) {
+	case int64:
+		switch m.mode {
+		case absolute:
+			if x == math.MinInt64 {
+				return nil, OpExecError(op, errIntOverflow)
+			}
+			if x < 0 {
+				return -x, nil
+			}
+			return x, nil
+		case squareRoot:
+			return m.sqrt(float64(x))
+		case sign:
+			return compareOrdered(x, 0), nil
+		}
+	case float64:
+		switch m.mode {
+		case absolute:
+			return math.Abs(x), nil
+		case squareRoot:
+			return m.sqrt(x)
+		case sign:
+			if math.IsNaN(x) {
+				return nil, OpExecError(op, errors.New("NaN has no sign"))
+			}
+			return compareOrdered(x, 0), nil
+		}
+	case Decimal:
+		switch m.mode {
+		case absolute:
+			if x.coef == math.MinInt64 {
+				return nil, OpExecError(op, errDecimalOverflow)
+			}
+			if x.coef < 0 {
+				x.coef = -x.coef
+			}
+			return x, nil
+		case squareRoot:
+			// the square root of a decimal is usually irrational
+			return nil, ParamTypeError(op, typeFloat, x)
+		case sign:
+			return compareOrdered(x.coef, 0), nil
+		}
 	default:
-		return false, errInvalidMode(c.mode, "comparison")
+		return nil, ParamTypeError(op, typeNumber, x)
 	}
+	return nil, errInvalidMode(m.mode, "math")
 }
 
-func comparisonEquals(_ *Ctx, params []Value) (Value, error) {
-	if len(params) == 2 {
-		return params[0] == params[1], nil
+func (m mathOperator) sqrt(x float64) (Value, error) {
+	if x < 0 {
+		return nil, OpExecError(modeNames[m.mode], fmt.Errorf("square root of negative number: %v", x))
 	}
+	return math.Sqrt(x), nil
+}
 
+// extremum returns the min or max param
+func (m mathOperator) extremum(params []Value) (Value, error) {
 	if len(params) < 2 {
-		return nil, errCnt2(equals, params)
+		return nil, errCnt2(m.mode, params)
 	}
 
-	v := params[0]
-	for _, p := range params {
-		if v != p {
-			return false, nil
-		}
+	kind, err := numberKind(m.mode, params)
+	if err != nil {
+		return nil, err
 	}
-	return true, nil
-}
 
-func comparisonNotEquals(_ *Ctx, params []Value) (Value, error) {
-	if len(params) != 2 {
-		return nil, errCnt2(notEquals, params)
+	res := params[0]
+	for _, p := range params[1:] {
+		c, err := compareNumbers(m.mode, p, res)
+		if err != nil {
+			return nil, err
+		}
+		if (m.mode == minimum && c < 0) || (m.mode == maximum && c > 0) {
+			res = p
+		}
 	}
-
-	return params[0] != params[1], nil
+	return toNumberKind(kind, res), nil
 }
 
-func comparisonBetween(_ *Ctx, params []Value) (Value, error) {
-	const op = "between"
+// clamp limits the number to the range, e.g. (clamp score 0 100)
+func (m mathOperator) clamp(params []Value) (Value, error) {
 	if len(params) != 3 {
-		return nil, ParamsCountError(op, 3, len(params))
+		return nil, ParamsCountError(modeNames[m.mode], 3, len(params))
 	}
 
-	v, ok := params[0].(int64)
-	if !ok {
-		return nil, errTypeInt(between, params[0])
+	kind, err := numberKind(m.mode, params)
+	if err != nil {
+		return nil, err
 	}
-	a, ok := params[1].(int64)
-	if !ok {
-		return nil, errTypeInt(between, params[1])
+
+	v, lo, hi := params[0], params[1], params[2]
+	if c, err := compareNumbers(m.mode, lo, hi); err != nil {
+		return nil, err
+	} else if c > 0 {
+		return nil, OpExecError(modeNames[m.mode], fmt.Errorf("invalid range: [%v, %v]", lo, hi))
 	}
-	b, ok := params[2].(int64)
-	if !ok {
-		return nil, errTypeInt(between, params[2])
+
+	if c, _ := compareNumbers(m.mode, v, lo); c < 0 {
+		v = lo
+	} else if c, _ = compareNumbers(m.mode, v, hi); c > 0 {
+		v = hi
 	}
-	return a <= v && v <= b, nil
+	return toNumberKind(kind, v), nil
 }
 
-func listIn(_ *Ctx, params []Value) (Value, error) {
-	const op = "in"
+func (m mathOperator) pow(params []Value) (Value, error) {
+	op := modeNames[m.mode]
 	if len(params) != 2 {
-		return nil, errCnt2(in, params)
+		return nil, errCnt2(m.mode, params)
 	}
-	switch v := params[0].(type) {
-	case string:
-		switch coll := params[1].(type) {
-		case []string:
-			for _, i := range coll {
-				if i == v {
-					return true, nil
+
+	kind, err := numberKind(m.mode, params)
+	if err != nil {
+		return nil, err
+	}
+
+	y, isInt := params[1].(int64)
+	switch {
+	case kind == typeInt && y >= 0:
+		x := params[0].(int64)
+		res := int64(1)
+		for ; y > 0; y >>= 1 {
+			if y&1 == 1 {
+				if res, err = mulInt64(res, x); err != nil {
+					return nil, OpExecError(op, err)
+				}
+			}
+			if y > 1 {
+				if x, err = mulInt64(x, x); err != nil {
+					return nil, OpExecError(op, err)
 				}
 			}
-			return false, nil
-		case map[string]struct{}:
-			_, exist := coll[v]
-			return exist, nil
-		default:
-			return nil, ParamTypeError(op, typeStrList, params[1])
 		}
-	case int64:
-		switch coll := params[1].(type) {
-		case []int64:
-			for _, i := range coll {
-				if i == v {
-					return true, nil
+		return res, nil
+	case kind == typeDecimal:
+		// the decimal can only be raised to a non-negative integer power
+		if !isInt || y < 0 {
+			return nil, OpExecError(op, fmt.Errorf("invalid decimal exponent: %v", params[1]))
+		}
+
+		rounding := defaultDecimalRounding
+		if m.rounding != nil {
+			rounding = *m.rounding
+		}
+
+		x, _ := toDecimal(params[0])
+		res := Decimal{coef: 1}
+		for ; y > 0; y >>= 1 {
+			if y&1 == 1 {
+				if res, err = res.mul(x, rounding.Mode); err != nil {
+					return nil, OpExecError(op, err)
 				}
 			}
-			return false, nil
-		case []string: // the empty list is parsed to a string list
-			if len(coll) == 0 {
-				return false, nil
+			if y > 1 {
+				if x, err = x.mul(x, rounding.Mode); err != nil {
+					return nil, OpExecError(op, err)
+				}
 			}
-		case map[int64]struct{}:
-			_, exist := coll[v]
-			return exist, nil
 		}
-		return nil, ParamTypeError(op, typeIntList, params[1])
+		return res, nil
+	default:
+		// the negative integer power is a float, e.g. (pow 2 -1) is 0.5
+		x, _ := toFloat(params[0])
+		e, _ := toFloat(params[1])
+		res := math.Pow(x, e)
+		if math.IsInf(res, 0) || math.IsNaN(res) {
+			return nil, OpExecError(op, fmt.Errorf("float overflow: %v", res))
+		}
+		return res, nil
 	}
-	return nil, OpExecError(op, errors.New("unsupported list type"))
 }
 
-func listOverlap(_ *Ctx, params []Value) (Value, error) {
-	const op = "overlap"
-	if len(params) != 2 {
-		return nil, errCnt2(overlap, params)
+// round rounds the number to the optional count of digits after the decimal point,
+// the decimal is rounded by the rounding mode of the config (RoundHalfUp by default)
+func (m mathOperator) round(params []Value) (Value, error) {
+	op := modeNames[m.mode]
+	if len(params) != 1 && len(params) != 2 {
+		return nil, errCntRange(op, 1, 2, len(params))
 	}
 
-	switch A := params[0].(type) {
-	case []string:
-		B, ok := params[1].([]string)
+	var digits int64
+	if len(params) == 2 {
+		var ok bool
+		digits, ok = params[1].(int64)
 		if !ok {
-			return nil, ParamTypeError(op, typeStrList, params[1])
+			return nil, errTypeInt(m.mode, params[1])
 		}
-		if len(A)+len(B) < 100 {
-			for _, i := range A {
-				for _, j := range B {
-					if i == j {
-						return true, nil
-					}
-				}
-			}
-			return false, nil
+		if digits < 0 || digits > maxDecimalScale {
+			return nil, OpExecError(op, fmt.Errorf("digits out of range: %d", digits))
 		}
-		if len(A) > len(B) {
-			A, B = B, A
+	}
+
+	switch x := params[0].(type) {
+	case int64:
+		return x, nil
+	case float64:
+		p := math.Pow10(int(digits))
+		switch m.mode {
+		case floor:
+			return math.Floor(x*p) / p, nil
+		case ceil:
+			return math.Ceil(x*p) / p, nil
+		default:
+			return math.Round(x*p) / p, nil
 		}
-		set := make(map[string]struct{}, len(A))
-		for _, i := range A {
-			set[i] = empty
+	case Decimal:
+		mode := defaultDecimalRounding.Mode
+		if m.rounding != nil {
+			mode = m.rounding.Mode
 		}
-		for _, i := range B {
-			if _, exist := set[i]; exist {
-				return true, nil
+		return x.round(int32(digits), mode, m.mode == floor, m.mode == ceil), nil
+	default:
+		return nil, ParamTypeError(op, typeNumber, x)
+	}
+}
+
+// numberKind returns the result type of the numeric params, the int64 params are promoted
+// to float64 when there are float params, or to Decimal when there are decimal params
+func numberKind(m mode, params []Value) (string, error) {
+	kind := typeInt
+	for _, p := range params {
+		switch p.(type) {
+		case int64:
+		case float64:
+			if kind == typeDecimal {
+				return "", ParamTypeError(modeNames[m], typeDecimal, p)
 			}
-		}
-		return false, nil
-	case []int64:
-		switch B := params[1].(type) {
-		case []int64:
-			if len(A)+len(B) < 100 {
-				for _, i := range A {
-					for _, j := range B {
-						if i == j {
-							return true, nil
-						}
-					}
-				}
-				return false, nil
+			kind = typeFloat
+		case Decimal:
+			if kind == typeFloat {
+				return "", ParamTypeError(modeNames[m], typeFloat, p)
+			}
+			kind = typeDecimal
+		default:
+			return "", errTypeNumber(m, p)
+		}
+	}
+	return kind, nil
+}
+
+func toNumberKind(kind string, v Value) Value {
+	switch kind {
+	case typeFloat:
+		f, _ := toFloat(v)
+		return f
+	case typeDecimal:
+		d, _ := toDecimal(v)
+		return d
+	}
+	return v
+}
+
+// mulInt64 multiplies the two integers, it reports the overflow instead of wrapping
+func mulInt64(a, b int64) (int64, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	c := a * b
+	if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, errIntOverflow
+	}
+	return c, nil
+}
+
+type logic struct {
+	mode mode
+}
+
+func (c logic) execute(_ *Ctx, params []Value) (Value, error) {
+	if len(params) < 2 {
+		return nil, errCnt2(c.mode, params)
+	}
+
+	var res bool
+	for i, p := range params {
+		v, ok := p.(bool)
+		if !ok {
+			return nil, errTypeBool(c.mode, p)
+		}
+		if i == 0 {
+			res = v
+		} else {
+			switch c.mode {
+			case and:
+				res = res && v
+			case or:
+				res = res || v
+			case xor:
+				res = res != v
+			default:
+				return false, errInvalidMode(c.mode, "logic")
+			}
+		}
+	}
+	return res, nil
+}
+
+func logicNot(_ *Ctx, params []Value) (Value, error) {
+	const op = "not"
+	if len(params) != 1 {
+		return nil, ParamsCountError(op, 1, len(params))
+	}
+	v, ok := params[0].(bool)
+	if !ok {
+		return nil, ParamTypeError(op, typeBool, params[0])
+	}
+	return !v, nil
+}
+
+type comparison struct {
+	mode     mode
+	ordering ordering
+}
+
+func (c comparison) execute(_ *Ctx, params []Value) (Value, error) {
+	if c.mode == between {
+		return c.between(params)
+	}
+
+	if len(params) != 2 {
+		return nil, errCnt2(c.mode, params)
+	}
+
+	i, ok1 := params[0].(int64)
+	j, ok2 := params[1].(int64)
+	if ok1 && ok2 {
+		return compare(c.mode, i, j)
+	}
+
+	res, err := c.ordering.compare(c.mode, params[0], params[1])
+	if err != nil {
+		return nil, err
+	}
+	return compare(c.mode, res, 0)
+}
+
+func (c comparison) between(params []Value) (Value, error) {
+	const op = "between"
+	if len(params) != 3 {
+		return nil, ParamsCountError(op, 3, len(params))
+	}
+
+	v, ok1 := params[0].(int64)
+	a, ok2 := params[1].(int64)
+	b, ok3 := params[2].(int64)
+	if ok1 && ok2 && ok3 {
+		return a <= v && v <= b, nil
+	}
+
+	lo, err := c.ordering.compare(between, params[1], params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	hi, err := c.ordering.compare(between, params[0], params[2])
+	if err != nil {
+		return nil, err
+	}
+	return lo <= 0 && hi <= 0, nil
+}
+
+func orderedComparison(m mode) func(cc *Config) Operator {
+	return func(cc *Config) Operator {
+		return comparison{mode: m, ordering: ordering{collation: cc.Collation, types: cc.Orderings}}.execute
+	}
+}
+
+func compare[T int64 | float64](m mode, i, j T) (Value, error) {
+	switch m {
+	case greater:
+		return i > j, nil
+	case less:
+		return i < j, nil
+	case greaterEquals:
+		return i >= j, nil
+	case lessEquals:
+		return i <= j, nil
+	default:
+		return false, errInvalidMode(m, "comparison")
+	}
+}
+
+var errNullParam = errors.New("unexpected null param")
+
+// isNull checks if the param is null, e.g. (is_null user.nickname)
+func isNull(_ *Ctx, params []Value) (Value, error) {
+	const op = "is_null"
+	if len(params) != 1 {
+		return nil, ParamsCountError(op, 1, len(params))
+	}
+	return params[0] == nil, nil
+}
+
+// equality is the eq and ne operators, null is only equal to null, or an error is returned for the null params
+// when the StrictNull option is enabled.
+// The params of mismatched types are not equal, or an error is returned when the StrictEquality option is enabled
+type equality struct {
+	mode       mode
+	strictNull bool
+	strict     bool
+}
+
+func configurableEquality(m mode) func(cc *Config) Operator {
+	return func(cc *Config) Operator {
+		return equality{
+			mode:       m,
+			strictNull: cc.CompileOptions[StrictNull],
+			strict:     cc.CompileOptions[StrictEquality],
+		}.execute
+	}
+}
+
+func (e equality) execute(_ *Ctx, params []Value) (Value, error) {
+	if e.strictNull {
+		for _, p := range params {
+			if p == nil {
+				return nil, OpExecError(modeNames[e.mode], errNullParam)
+			}
+		}
+	}
+
+	switch e.mode {
+	case equals:
+		if len(params) < 2 {
+			return nil, errCnt2(equals, params)
+		}
+
+		v := params[0]
+		for _, p := range params[1:] {
+			equal, err := e.equal(v, p)
+			if err != nil || !equal {
+				return false, err
+			}
+		}
+		return true, nil
+	case notEquals:
+		if len(params) != 2 {
+			return nil, errCnt2(notEquals, params)
+		}
+		equal, err := e.equal(params[0], params[1])
+		if err != nil {
+			return nil, err
+		}
+		return !equal, nil
+	default:
+		return nil, errInvalidMode(e.mode, "equality")
+	}
+}
+
+func (e equality) equal(a, b Value) (bool, error) {
+	equal, comparable := equalValues(a, b)
+	if !comparable && e.strict {
+		return false, OpExecError(modeNames[e.mode], fmt.Errorf("mismatched param types: %T, %T", a, b))
+	}
+	return equal, nil
+}
+
+// equalValues checks if the two values are equal. The integers of any width are compared by value,
+// and the int64 value is promoted to float64 (or decimal) when it is compared with a float64 (or decimal) value.
+// The lists and maps are compared element by element. The second result is false when the types mismatch,
+// e.g. a string is compared with an integer
+func equalValues(a, b Value) (equal, comparable bool) {
+	a, b = unifyType(a), unifyType(b)
+	if a == nil || b == nil {
+		return a == b, true
+	}
+
+	switch x := a.(type) {
+	case bool:
+		y, ok := b.(bool)
+		return ok && x == y, ok
+	case string:
+		y, ok := b.(string)
+		return ok && x == y, ok
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			return x == y, true
+		case float64:
+			return float64(x) == y, true
+		case Decimal:
+			return y.Cmp(Decimal{coef: x}) == 0, true
+		case time.Time:
+			// the int64 is the Unix seconds when it is compared with a time
+			return time.Unix(x, 0).Equal(y), true
+		}
+		return false, false
+	case float64:
+		y, ok := toFloat(b)
+		return ok && x == y, ok
+	case Decimal:
+		y, ok := toDecimal(b)
+		return ok && x.Cmp(y) == 0, ok
+	case time.Time:
+		// the same instant in different time zones is equal
+		y, ok := timeOf(b)
+		return ok && x.Equal(y), ok
+	case SemVer:
+		// the build metadata is ignored
+		y, ok := b.(SemVer)
+		return ok && x.Cmp(y) == 0, ok
+	}
+
+	ra, rb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case isListKind(ra.Kind()) && isListKind(rb.Kind()):
+		if ra.Len() != rb.Len() {
+			return false, true
+		}
+		for i := 0; i < ra.Len(); i++ {
+			if equal, comparable = equalValues(ra.Index(i).Interface(), rb.Index(i).Interface()); !equal {
+				return false, comparable
+			}
+		}
+		return true, true
+	case ra.Kind() == reflect.Map && rb.Kind() == reflect.Map:
+		return equalMaps(ra, rb)
+	case ra.Type() == rb.Type():
+		// the values of other types (e.g. the structs returned by the customized operators) are compared deeply,
+		// the == operator panics when the values are uncomparable
+		return reflect.DeepEqual(a, b), true
+	}
+	return false, false
+}
+
+// equalMaps checks if the two maps have the same keys and the equal values,
+// the keys should be both strings or both integers
+func equalMaps(a, b reflect.Value) (equal, comparable bool) {
+	ka, kb := a.Type().Key(), b.Type().Key()
+	if ka != kb && (mapKeyKind(ka) == "" || mapKeyKind(ka) != mapKeyKind(kb)) {
+		return false, false
+	}
+
+	if a.Len() != b.Len() {
+		return false, true
+	}
+
+	iter := a.MapRange()
+	for iter.Next() {
+		v := b.MapIndex(iter.Key().Convert(kb))
+		if !v.IsValid() {
+			return false, true
+		}
+		if equal, comparable = equalValues(iter.Value().Interface(), v.Interface()); !equal {
+			return false, comparable
+		}
+	}
+	return true, true
+}
+
+func mapKeyKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return typeStr
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return typeInt
+	}
+	return ""
+}
+
+func isListKind(k reflect.Kind) bool {
+	return k == reflect.Slice || k == reflect.Array
+}
+
+// containsValue checks if the list contains an element equal to v, it is used by
+// the list operators on the lists of mixed types, bools or nested lists
+func containsValue(list reflect.Value, v Value) bool {
+	for i := 0; i < list.Len(); i++ {
+		if equal, _ := equalValues(v, list.Index(i).Interface()); equal {
+			return true
+		}
+	}
+	return false
+}
+
+func listIn(_ *Ctx, params []Value) (Value, error) {
+	const op = "in"
+	if len(params) != 2 {
+		return nil, errCnt2(in, params)
+	}
+
+	// the lists of mixed types, bools or nested lists, e.g. (in tier (GOLD 1)), (in true (true false))
+	if coll, ok := params[1].([]Value); ok {
+		return containsValue(reflect.ValueOf(coll), params[0]), nil
+	}
+
+	switch v := params[0].(type) {
+	case string:
+		switch coll := params[1].(type) {
+		case []string:
+			for _, i := range coll {
+				if i == v {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]struct{}:
+			_, exist := coll[v]
+			return exist, nil
+		default:
+			return nil, ParamTypeError(op, typeStrList, params[1])
+		}
+	case int64:
+		switch coll := params[1].(type) {
+		case []int64:
+			for _, i := range coll {
+				if i == v {
+					return true, nil
+				}
+			}
+			return false, nil
+		case []float64:
+			for _, f := range coll {
+				if f == float64(v) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case []string: // the empty list is parsed to a string list
+			if len(coll) == 0 {
+				return false, nil
+			}
+		case map[int64]struct{}:
+			_, exist := coll[v]
+			return exist, nil
+		}
+		return nil, ParamTypeError(op, typeIntList, params[1])
+	case float64:
+		switch coll := params[1].(type) {
+		case []float64:
+			for _, f := range coll {
+				if f == v {
+					return true, nil
+				}
+			}
+			return false, nil
+		case []int64:
+			for _, i := range coll {
+				if float64(i) == v {
+					return true, nil
+				}
+			}
+			return false, nil
+		case []string: // the empty list is parsed to a string list
+			if len(coll) == 0 {
+				return false, nil
+			}
+		}
+		return nil, ParamTypeError(op, typeFloatList, params[1])
+	}
+	return nil, OpExecError(op, errors.New("unsupported list type"))
+}
+
+func listOverlap(_ *Ctx, params []Value) (Value, error) {
+	const op = "overlap"
+	if len(params) != 2 {
+		return nil, errCnt2(overlap, params)
+	}
+
+	// the lists of mixed types, bools or nested lists, e.g. (overlap (1 "a") ("a"))
+	_, mixedA := params[0].([]Value)
+	_, mixedB := params[1].([]Value)
+	if mixedA || mixedB {
+		ra, rb := reflect.ValueOf(params[0]), reflect.ValueOf(params[1])
+		if !isListKind(ra.Kind()) {
+			return nil, ParamTypeError(op, typeList, params[0])
+		}
+		if !isListKind(rb.Kind()) {
+			return nil, ParamTypeError(op, typeList, params[1])
+		}
+		for i := 0; i < ra.Len(); i++ {
+			if containsValue(rb, ra.Index(i).Interface()) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	switch A := params[0].(type) {
+	case []string:
+		B, ok := params[1].([]string)
+		if !ok {
+			return nil, ParamTypeError(op, typeStrList, params[1])
+		}
+		if len(A)+len(B) < 100 {
+			for _, i := range A {
+				for _, j := range B {
+					if i == j {
+						return true, nil
+					}
+				}
+			}
+			return false, nil
+		}
+		if len(A) > len(B) {
+			A, B = B, A
+		}
+		set := make(map[string]struct{}, len(A))
+		for _, i := range A {
+			set[i] = empty
+		}
+		for _, i := range B {
+			if _, exist := set[i]; exist {
+				return true, nil
+			}
+		}
+		return false, nil
+	case []int64:
+		switch B := params[1].(type) {
+		case []int64:
+			if len(A)+len(B) < 100 {
+				for _, i := range A {
+					for _, j := range B {
+						if i == j {
+							return true, nil
+						}
+					}
+				}
+				return false, nil
 			}
 			if len(A) > len(B) {
 				A, B = B, A
@@ -461,17 +1479,531 @@ func listOverlap(_ *Ctx, params []Value) (Value, error) {
 				}
 			}
 			return false, nil
+		case []float64:
+			a, _ := toFloatList(A)
+			return overlapFloats(a, B), nil
 		case []string:
 			// the empty list is parsed to a string list
 			if len(B) != 0 {
 				return nil, ParamTypeError(op, typeStrList, params[1])
 			}
-			return false, nil
-		default:
-			return nil, ParamTypeError(op, typeStrList, params[1])
+			return false, nil
+		default:
+			return nil, ParamTypeError(op, typeStrList, params[1])
+		}
+	case []float64:
+		if B, ok := params[1].([]string); ok && len(B) == 0 {
+			// the empty list is parsed to a string list
+			return false, nil
+		}
+		B, ok := toFloatList(params[1])
+		if !ok {
+			return nil, ParamTypeError(op, typeFloatList, params[1])
+		}
+		return overlapFloats(A, B), nil
+	}
+	return nil, ParamTypeError(op, typeStrList, params[0])
+}
+
+func overlapFloats(A, B []float64) bool {
+	if len(A)+len(B) < 100 {
+		for _, i := range A {
+			for _, j := range B {
+				if i == j {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if len(A) > len(B) {
+		A, B = B, A
+	}
+	set := make(map[float64]struct{}, len(A))
+	for _, i := range A {
+		set[i] = empty
+	}
+	for _, i := range B {
+		if _, exist := set[i]; exist {
+			return true
+		}
+	}
+	return false
+}
+
+// element reads an element of a list or a map, e.g. (get assignments "exp_42"), (nth scores 0).
+// The get_or_null returns null instead of the error when the index is out of range or the key is not found
+type element struct {
+	mode mode
+}
+
+func (e element) execute(_ *Ctx, params []Value) (Value, error) {
+	op := modeNames[e.mode]
+	if e.mode == firstElem || e.mode == lastElem {
+		if len(params) != 1 {
+			return nil, ParamsCountError(op, 1, len(params))
+		}
+
+		size, ok := listLen(params[0])
+		if !ok {
+			return nil, ParamTypeError(op, typeList, params[0])
+		}
+		if size == 0 {
+			return nil, OpExecError(op, errors.New("empty list"))
+		}
+
+		if e.mode == firstElem {
+			return listElem(params[0], 0), nil
+		}
+		return listElem(params[0], size-1), nil
+	}
+
+	if len(params) != 2 {
+		return nil, errCnt2(e.mode, params)
+	}
+
+	coll, key := params[0], params[1]
+	if coll == nil && e.mode == getOrNull {
+		return nil, nil
+	}
+
+	if size, ok := listLen(coll); ok {
+		i, ok := key.(int64)
+		if !ok {
+			return nil, errTypeInt(e.mode, key)
+		}
+		if i < 0 || i >= int64(size) {
+			if e.mode == getOrNull {
+				return nil, nil
+			}
+			return nil, OpExecError(op, fmt.Errorf("index out of range: %d, length: %d", i, size))
+		}
+		return listElem(coll, int(i)), nil
+	}
+
+	if e.mode == nthElem {
+		return nil, ParamTypeError(op, typeList, coll)
+	}
+
+	v, found, err := mapElem(op, coll, key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if e.mode == getOrNull {
+			return nil, nil
+		}
+		return nil, OpExecError(op, fmt.Errorf("key not found: %v", key))
+	}
+	return v, nil
+}
+
+// mapElem returns the value of the key in the map, the maps with string or integer keys are supported
+func mapElem(op string, m, key Value) (Value, bool, error) {
+	if s, ok := key.(string); ok {
+		switch m := m.(type) {
+		case map[string]interface{}:
+			v, found := m[s]
+			return unifyType(v), found, nil
+		case map[string]int64:
+			v, found := m[s]
+			return v, found, nil
+		case map[string]string:
+			v, found := m[s]
+			return v, found, nil
+		case map[string]float64:
+			v, found := m[s]
+			return v, found, nil
+		case map[string]Value:
+			v, found := m[s]
+			return v, found, nil
+		}
+	}
+
+	rv := reflect.ValueOf(m)
+	if rv.Kind() != reflect.Map {
+		return nil, false, ParamTypeError(op, typeList+" or "+typeMap, m)
+	}
+
+	var k reflect.Value
+	switch keyType := rv.Type().Key(); keyType.Kind() {
+	case reflect.String:
+		s, ok := key.(string)
+		if !ok {
+			return nil, false, ParamTypeError(op, typeStr, key)
+		}
+		k = reflect.ValueOf(s).Convert(keyType)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, ok := key.(int64)
+		if !ok {
+			return nil, false, ParamTypeError(op, typeInt, key)
+		}
+		k = reflect.ValueOf(i).Convert(keyType)
+	default:
+		return nil, false, ParamTypeError(op, typeMap, m)
+	}
+
+	v := rv.MapIndex(k)
+	if !v.IsValid() {
+		return nil, false, nil
+	}
+	return unifyType(v.Interface()), true, nil
+}
+
+// collect builds a list from the params, the list is []int64 or []string
+// when all the params are of the same type, otherwise it is []Value
+func collect(_ *Ctx, params []Value) (Value, error) {
+	b := &listBuilder{}
+	for _, p := range params {
+		b.append(p)
+	}
+	return b.build(), nil
+}
+
+// dict builds a map from the key value pairs, e.g. (dict "US" 10 "CA" 8), the keys should be strings.
+// It is the runtime form of the map literal {"US": 10, "CA": 8} whose values are not all constants
+func dict(_ *Ctx, params []Value) (Value, error) {
+	const op = "dict"
+	if len(params)%2 != 0 {
+		return nil, OpExecError(op, fmt.Errorf("the key %v has no value", params[len(params)-1]))
+	}
+
+	keys := make([]string, 0, len(params)/2)
+	vals := make([]Value, 0, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		k, ok := params[i].(string)
+		if !ok {
+			return nil, ParamTypeError(op, typeStr, params[i])
+		}
+		keys, vals = append(keys, k), append(vals, params[i+1])
+	}
+
+	m, err := mapLiteral(keys, vals)
+	if err != nil {
+		return nil, OpExecError(op, err)
+	}
+	return m, nil
+}
+
+// exists checks if the probed variable is present, e.g. (exists promo_code).
+// Under TryEval, the variable which is not cached is unknown, so the result is DNE
+func exists(_ *Ctx, params []Value) (Value, error) {
+	if params[0] == DNE {
+		return DNE, nil
+	}
+	return params[0] != missing, nil
+}
+
+// coalesce returns the first present and non-null param, e.g. (coalesce nickname name "guest"),
+// it returns null when there is no such param. Under TryEval, the result is DNE
+// when an unknown param is met before the present one
+func coalesce(_ *Ctx, params []Value) (Value, error) {
+	for _, p := range params {
+		switch p {
+		case DNE:
+			return DNE, nil
+		case missing, nil:
+			continue
+		}
+		return p, nil
+	}
+	return nil, nil
+}
+
+func strConcat(_ *Ctx, params []Value) (Value, error) {
+	const op = "concat"
+	if len(params) < 2 {
+		return nil, ParamsCountError(op, 2, len(params))
+	}
+
+	var sb strings.Builder
+	for _, p := range params {
+		s, ok := p.(string)
+		if !ok {
+			return nil, ParamTypeError(op, typeStr, p)
+		}
+		sb.WriteString(s)
+	}
+	return sb.String(), nil
+}
+
+// strLen returns the count of characters of a string, or the length of a list
+func strLen(_ *Ctx, params []Value) (Value, error) {
+	const op = "len"
+	if len(params) != 1 {
+		return nil, ParamsCountError(op, 1, len(params))
+	}
+
+	if s, ok := params[0].(string); ok {
+		return int64(utf8.RuneCountInString(s)), nil
+	}
+
+	if n, ok := listLen(params[0]); ok {
+		return int64(n), nil
+	}
+	return nil, ParamTypeError(op, typeStr, params[0])
+}
+
+// strTransform returns the operator which transforms a string by fn, e.g. (lower s)
+func strTransform(op string, fn func(string) string) Operator {
+	return func(_ *Ctx, params []Value) (Value, error) {
+		if len(params) != 1 {
+			return nil, ParamsCountError(op, 1, len(params))
+		}
+
+		s, ok := params[0].(string)
+		if !ok {
+			return nil, ParamTypeError(op, typeStr, params[0])
+		}
+		return fn(s), nil
+	}
+}
+
+// strPredicate returns the operator which checks a string with a substring by fn, e.g. (contains s sub)
+func strPredicate(op string, fn func(s, sub string) bool) Operator {
+	return func(_ *Ctx, params []Value) (Value, error) {
+		s, sub, err := DestructParamsStr2(op, params)
+		if err != nil {
+			return nil, err
+		}
+		return fn(s, sub), nil
+	}
+}
+
+// strTrim removes the leading and trailing white spaces,
+// or the characters in the cutset if it is given, e.g. (trim s "-_")
+func strTrim(_ *Ctx, params []Value) (Value, error) {
+	const op = "trim"
+	switch len(params) {
+	case 1:
+		s, ok := params[0].(string)
+		if !ok {
+			return nil, ParamTypeError(op, typeStr, params[0])
+		}
+		return strings.TrimSpace(s), nil
+	case 2:
+		s, cutset, err := DestructParamsStr2(op, params)
+		if err != nil {
+			return nil, err
+		}
+		return strings.Trim(s, cutset), nil
+	default:
+		return nil, errCntRange(op, 1, 2, len(params))
+	}
+}
+
+// strSubstr returns the substring from the start character with the optional length,
+// the substring is truncated when it is out of the string, e.g. (substr "hello" 1 3) is "ell"
+func strSubstr(_ *Ctx, params []Value) (Value, error) {
+	const op = "substr"
+	if len(params) != 2 && len(params) != 3 {
+		return nil, errCntRange(op, 2, 3, len(params))
+	}
+
+	s, ok := params[0].(string)
+	if !ok {
+		return nil, ParamTypeError(op, typeStr, params[0])
+	}
+
+	start, ok := params[1].(int64)
+	if !ok {
+		return nil, ParamTypeError(op, typeInt, params[1])
+	}
+
+	size := int64(math.MaxInt64)
+	if len(params) == 3 {
+		size, ok = params[2].(int64)
+		if !ok {
+			return nil, ParamTypeError(op, typeInt, params[2])
+		}
+	}
+
+	if start < 0 || size < 0 {
+		return nil, OpExecError(op, fmt.Errorf("negative index: %d, %d", start, size))
+	}
+
+	runes := []rune(s)
+	if start >= int64(len(runes)) {
+		return "", nil
+	}
+	if size > int64(len(runes))-start {
+		size = int64(len(runes)) - start
+	}
+	return string(runes[start : start+size]), nil
+}
+
+// strIndexOf returns the index of the first character of the substring, or -1 if it is not present
+func strIndexOf(_ *Ctx, params []Value) (Value, error) {
+	const op = "index_of"
+	s, sub, err := DestructParamsStr2(op, params)
+	if err != nil {
+		return nil, err
+	}
+
+	i := strings.Index(s, sub)
+	if i < 0 {
+		return int64(-1), nil
+	}
+	return int64(utf8.RuneCountInString(s[:i])), nil
+}
+
+func strReplace(_ *Ctx, params []Value) (Value, error) {
+	const op = "replace"
+	if len(params) != 3 {
+		return nil, ParamsCountError(op, 3, len(params))
+	}
+
+	var strs [3]string
+	for i, p := range params {
+		s, ok := p.(string)
+		if !ok {
+			return nil, ParamTypeError(op, typeStr, p)
+		}
+		strs[i] = s
+	}
+	return strings.ReplaceAll(strs[0], strs[1], strs[2]), nil
+}
+
+func strSplit(_ *Ctx, params []Value) (Value, error) {
+	const op = "split"
+	s, sep, err := DestructParamsStr2(op, params)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(s, sep), nil
+}
+
+func strJoin(_ *Ctx, params []Value) (Value, error) {
+	const op = "join"
+	if len(params) != 2 {
+		return nil, ParamsCountError(op, 2, len(params))
+	}
+
+	l, ok := params[0].([]string)
+	if !ok {
+		return nil, ParamTypeError(op, typeStrList, params[0])
+	}
+
+	sep, ok := params[1].(string)
+	if !ok {
+		return nil, ParamTypeError(op, typeStr, params[1])
+	}
+	return strings.Join(l, sep), nil
+}
+
+// regexOperator matches a string with a regex pattern, the pattern is
+// precompiled when it is a constant, otherwise it is compiled with the regexCache
+type regexOperator struct {
+	mode mode
+	re   *regexp.Regexp
+}
+
+func (r regexOperator) execute(_ *Ctx, params []Value) (Value, error) {
+	// extract has an optional param for the index of the capturing group
+	if len(params) != 2 && !(r.mode == extract && len(params) == 3) {
+		return nil, errCnt2(r.mode, params)
+	}
+
+	s, ok := params[0].(string)
+	if !ok {
+		return nil, errTypeStr(r.mode, params[0])
+	}
+
+	re := r.re
+	if re == nil {
+		pattern, ok := params[1].(string)
+		if !ok {
+			return nil, errTypeStr(r.mode, params[1])
+		}
+
+		var err error
+		re, err = regexCache.compile(pattern)
+		if err != nil {
+			return nil, OpExecError(modeNames[r.mode], err)
+		}
+	}
+
+	switch r.mode {
+	case matches:
+		return re.MatchString(s), nil
+	case find:
+		return re.FindString(s), nil
+	case extract:
+		// the first group by default, or the whole match if the pattern has no groups
+		group := int64(1)
+		if re.NumSubexp() == 0 {
+			group = 0
+		}
+		if len(params) == 3 {
+			group, ok = params[2].(int64)
+			if !ok {
+				return nil, errTypeInt(r.mode, params[2])
+			}
+		}
+
+		if group < 0 || group > int64(re.NumSubexp()) {
+			return nil, OpExecError(modeNames[r.mode], fmt.Errorf("capturing group out of range: %d", group))
 		}
+
+		res := re.FindStringSubmatch(s)
+		if res == nil {
+			return "", nil
+		}
+		return res[group], nil
+	default:
+		return nil, errInvalidMode(r.mode, "regex")
 	}
-	return nil, ParamTypeError(op, typeStrList, params[0])
+}
+
+func precompileRegex(m mode) func(pattern string) (Operator, error) {
+	return func(pattern string) (Operator, error) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return regexOperator{mode: m, re: re}.execute, nil
+	}
+}
+
+const regexCacheSize = 256
+
+// regexCache caches the compiled dynamic patterns,
+// an arbitrary pattern is evicted when the cache is full
+var regexCache = &boundedRegexCache{
+	size:     regexCacheSize,
+	patterns: make(map[string]*regexp.Regexp, regexCacheSize),
+}
+
+type boundedRegexCache struct {
+	mu       sync.RWMutex
+	size     int
+	patterns map[string]*regexp.Regexp
+}
+
+func (c *boundedRegexCache) compile(pattern string) (*regexp.Regexp, error) {
+	c.mu.RLock()
+	re, exist := c.patterns[pattern]
+	c.mu.RUnlock()
+	if exist {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.patterns) >= c.size {
+		for k := range c.patterns {
+			delete(c.patterns, k)
+			break
+		}
+	}
+	c.patterns[pattern] = re
+	return re, nil
 }
 
 const (
@@ -479,52 +2011,340 @@ const (
 	defaultDateLayout     = "2006-01-02"
 )
 
+// timeConvert parses the string into a time, e.g. (datetime "2021-01-01 09:00:00" "2006-01-02 15:04:05" "Asia/Tokyo"),
+// the string is parsed in the time zone of the optional last param, or in UTC. The result is a time value
+// which keeps the nanoseconds and the time zone, or the Unix seconds in the UnixTime mode
 type timeConvert struct {
 	mode   mode
 	layout string
+	unix   bool
+}
+
+func configurableTime(m mode, layout string) func(cc *Config) Operator {
+	return func(cc *Config) Operator {
+		return timeConvert{mode: m, layout: layout, unix: cc.CompileOptions[UnixTime]}.execute
+	}
 }
 
 func (c timeConvert) execute(_ *Ctx, params []Value) (Value, error) {
-	var layout string
+	op := modeNames[c.mode]
 	switch c.mode {
 	case datetime, date:
-		switch len(params) {
-		case 1:
-			layout = c.layout
-		case 2:
-			temp, ok := params[1].(string)
-			if !ok {
-				return nil, errTypeStr(c.mode, params[1])
-			}
-			layout = temp
-		default:
-			return nil, ParamsCountError(modeNames[c.mode], 1, len(params))
+		if len(params) < 1 || len(params) > 3 {
+			return nil, errCntRange(op, 1, 3, len(params))
 		}
 	case toTime, toDate:
-		if len(params) != 2 {
+		if len(params) != 2 && len(params) != 3 {
 			return nil, errCnt2(c.mode, params)
 		}
-		temp, ok := params[1].(string)
-		if !ok {
-			return nil, errTypeStr(c.mode, params[1])
-		}
-		layout = temp
 	case toDefaultTime, toDefaultDate:
 		if len(params) != 1 {
-			return nil, ParamsCountError(modeNames[c.mode], 1, len(params))
+			return nil, ParamsCountError(op, 1, len(params))
 		}
+	}
+
+	var (
 		layout = c.layout
+		loc    = time.UTC
+	)
+	for i, p := range params[1:] {
+		s, ok := p.(string)
+		if !ok {
+			return nil, errTypeStr(c.mode, p)
+		}
+
+		if i == 0 {
+			layout = s
+			continue
+		}
+
+		var err error
+		if loc, err = loadLocation(s); err != nil {
+			return nil, OpExecError(op, err)
+		}
 	}
 
 	v, ok := params[0].(string)
 	if !ok {
 		return nil, errTypeStr(c.mode, params[0])
 	}
-	t, err := time.Parse(layout, v)
+	t, err := time.ParseInLocation(layout, v, loc)
+	if err != nil {
+		return nil, OpExecError(op, err)
+	}
+
+	if c.unix {
+		return t.Unix(), nil
+	}
+	return t, nil
+}
+
+// inTimeZone converts the time to the time zone, e.g. (in_tz signup_time "Asia/Tokyo").
+// The instant is kept, the date and clock (e.g. for truncate_to_day) are in the time zone
+func inTimeZone(_ *Ctx, params []Value) (Value, error) {
+	const op = "in_tz"
+	if len(params) != 2 {
+		return nil, ParamsCountError(op, 2, len(params))
+	}
+
+	t, err := timeIn(op, params[0], params[1])
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// unixConvert builds the time from the Unix seconds or milliseconds, e.g. (from_unix_ms login_ms "Asia/Tokyo"),
+// the result is in the time zone of the optional param, or in UTC. It is the Unix seconds in the UnixTime mode,
+// and the milliseconds are truncated towards the past
+type unixConvert struct {
+	mode mode
+	unix bool
+}
+
+func configurableUnix(m mode) func(cc *Config) Operator {
+	return func(cc *Config) Operator {
+		return unixConvert{mode: m, unix: cc.CompileOptions[UnixTime]}.execute
+	}
+}
+
+func (c unixConvert) execute(_ *Ctx, params []Value) (Value, error) {
+	op := modeNames[c.mode]
+	if len(params) != 1 && len(params) != 2 {
+		return nil, errCntRange(op, 1, 2, len(params))
+	}
+
+	n, ok := params[0].(int64)
+	if !ok {
+		return nil, errTypeInt(c.mode, params[0])
+	}
+
+	t := time.Unix(n, 0).UTC()
+	if c.mode == fromUnixMs {
+		t = time.UnixMilli(n).UTC()
+	}
+
+	t, err := timeIn(op, t, params[1:]...)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.unix {
+		return t.Unix(), nil
+	}
+	return t, nil
+}
+
+// clock reads the current time from the Clock of the Ctx, e.g. (now) and (today "Asia/Tokyo"),
+// today is the start of the current day. The result is in the time zone of the optional param, or in UTC.
+// They depend on the current time, so they are never stateless and are not folded
+type clock struct {
+	mode mode
+	unix bool
+}
+
+func configurableClock(m mode) func(cc *Config) Operator {
+	return func(cc *Config) Operator {
+		return clock{mode: m, unix: cc.CompileOptions[UnixTime]}.execute
+	}
+}
+
+func (c clock) execute(ctx *Ctx, params []Value) (Value, error) {
+	op := modeNames[c.mode]
+	if len(params) > 1 {
+		return nil, errCntRange(op, 0, 1, len(params))
+	}
+
+	t, err := timeIn(op, ctx.currentTime().UTC(), params...)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.mode == today {
+		y, m, d := t.Date()
+		t = time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	}
+
+	if c.unix {
+		return t.Unix(), nil
+	}
+	return t, nil
+}
+
+// timeArithmetic is the operators on the times and the durations in seconds,
+// e.g. (> (since signup_time) 7d), (add_duration start 36h) and (truncate_to_day (now)).
+// The times are the time values or the Unix seconds. The since and until are relative
+// to the current time of the Clock, so they are not stateless
+type timeArithmetic struct {
+	mode mode
+}
+
+const secondsPerDay = 24 * 3600
+
+func (c timeArithmetic) execute(ctx *Ctx, params []Value) (Value, error) {
+	op := modeNames[c.mode]
+	if c.mode == addDuration {
+		if len(params) != 2 {
+			return nil, errCnt2(c.mode, params)
+		}
+	} else if len(params) != 1 {
+		return nil, ParamsCountError(op, 1, len(params))
+	}
+
+	if t, ok := params[0].(time.Time); ok {
+		return c.executeTime(ctx, t, params)
+	}
+
+	t, ok := params[0].(int64)
+	if !ok {
+		return nil, ParamTypeError(op, typeTime, params[0])
+	}
+
+	switch c.mode {
+	case since:
+		return ctx.currentTime().Unix() - t, nil
+	case until:
+		return t - ctx.currentTime().Unix(), nil
+	case addDuration:
+		d, ok := params[1].(int64)
+		if !ok {
+			return nil, errTypeInt(c.mode, params[1])
+		}
+		if (d > 0 && t > math.MaxInt64-d) || (d < 0 && t < math.MinInt64-d) {
+			return nil, OpExecError(op, errIntOverflow)
+		}
+		return t + d, nil
+	case truncateToDay:
+		// the days are in UTC, the negative times are truncated towards the past
+		r := t % secondsPerDay
+		if r < 0 {
+			r += secondsPerDay
+		}
+		return t - r, nil
+	default:
+		return nil, errInvalidMode(c.mode, "time")
+	}
+}
+
+func (c timeArithmetic) executeTime(ctx *Ctx, t time.Time, params []Value) (Value, error) {
+	switch c.mode {
+	case since:
+		return int64(ctx.currentTime().Sub(t) / time.Second), nil
+	case until:
+		return int64(t.Sub(ctx.currentTime()) / time.Second), nil
+	case addDuration:
+		d, ok := params[1].(int64)
+		if !ok {
+			return nil, errTypeInt(c.mode, params[1])
+		}
+		return t.Add(time.Duration(d) * time.Second), nil
+	case truncateToDay:
+		// the day is in the time zone of the time, e.g. the birthday of a user in Tokyo
+		y, m, d := t.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location()), nil
+	default:
+		return nil, errInvalidMode(c.mode, "time")
+	}
+}
+
+// calendar extracts the calendar component of the time, e.g. (hour login_time "Asia/Tokyo"),
+// the component is in the time zone of the optional last param, or in the time zone of the time.
+// The weekday is 0 (Sunday) to 6 (Saturday), the months and the days start from 1
+type calendar struct {
+	mode mode
+}
+
+func (c calendar) execute(_ *Ctx, params []Value) (Value, error) {
+	op := modeNames[c.mode]
+	if len(params) != 1 && len(params) != 2 {
+		return nil, errCntRange(op, 1, 2, len(params))
+	}
+
+	t, err := timeIn(op, params[0], params[1:]...)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.mode {
+	case year:
+		return int64(t.Year()), nil
+	case month:
+		return int64(t.Month()), nil
+	case day:
+		return int64(t.Day()), nil
+	case weekday:
+		return int64(t.Weekday()), nil
+	case hour:
+		return int64(t.Hour()), nil
+	case minute:
+		return int64(t.Minute()), nil
+	case dayOfYear:
+		return int64(t.YearDay()), nil
+	default:
+		return nil, errInvalidMode(c.mode, "calendar")
+	}
+}
+
+// scheduleOperator checks whether the time is in the schedule, e.g. (in_schedule t "MON-FRI 09:00-17:00" "Asia/Tokyo"),
+// the time is matched in the time zone of the optional last param, or in the time zone of the time.
+// The schedule is precompiled when it is a constant, see parseSchedule for the grammar
+type scheduleOperator struct {
+	sc *schedule
+}
+
+func (s scheduleOperator) execute(_ *Ctx, params []Value) (Value, error) {
+	const op = "in_schedule"
+	if len(params) != 2 && len(params) != 3 {
+		return nil, errCntRange(op, 2, 3, len(params))
+	}
+
+	sc := s.sc
+	if sc == nil {
+		str, ok := params[1].(string)
+		if !ok {
+			return nil, ParamTypeError(op, typeStr, params[1])
+		}
+
+		var err error
+		if sc, err = parseSchedule(str); err != nil {
+			return nil, OpExecError(op, err)
+		}
+	}
+
+	t, err := timeIn(op, params[0], params[2:]...)
+	if err != nil {
+		return nil, err
+	}
+	return sc.match(t), nil
+}
+
+func precompileSchedule(s string) (Operator, error) {
+	sc, err := parseSchedule(s)
 	if err != nil {
-		return nil, OpExecError(modeNames[c.mode], err)
+		return nil, err
+	}
+	return scheduleOperator{sc: sc}.execute, nil
+}
+
+func decimalConvert(_ *Ctx, params []Value) (Value, error) {
+	const op = "decimal"
+	if len(params) != 1 {
+		return nil, ParamsCountError(op, 1, len(params))
+	}
+
+	switch v := params[0].(type) {
+	case string:
+		d, err := ParseDecimal(v)
+		if err != nil {
+			return nil, OpExecError(op, err)
+		}
+		return d, nil
+	case int64:
+		return Decimal{coef: v}, nil
+	case Decimal:
+		return v, nil
 	}
-	return t.Unix(), nil
+	return nil, ParamTypeError(op, typeStr, params[0])
 }
 
 type versionConvert struct {
@@ -573,6 +2393,75 @@ func (c versionConvert) execute(_ *Ctx, params []Value) (Value, error) {
 	return res, nil
 }
 
+// semverConvert parses the string into a semantic version, e.g. (semver "2.3.4-beta.1+abc")
+func semverConvert(_ *Ctx, params []Value) (Value, error) {
+	const op = "semver"
+	if len(params) != 1 {
+		return nil, ParamsCountError(op, 1, len(params))
+	}
+
+	switch v := params[0].(type) {
+	case string:
+		sv, err := ParseSemVer(v)
+		if err != nil {
+			return nil, OpExecError(op, err)
+		}
+		return sv, nil
+	case SemVer:
+		return v, nil
+	}
+	return nil, ParamTypeError(op, typeStr, params[0])
+}
+
+// versionSatisfies checks whether the version satisfies the constraint, e.g.
+// (version_satisfies app_version ">=2.3.0 <3.0.0 || ^3.1"), see versionConstraint for the grammar.
+// The version is a semantic version or its string, the constraint is precompiled when it is a constant
+type versionSatisfies struct {
+	constraint versionConstraint
+}
+
+func (s versionSatisfies) execute(_ *Ctx, params []Value) (Value, error) {
+	const op = "version_satisfies"
+	if len(params) != 2 {
+		return nil, ParamsCountError(op, 2, len(params))
+	}
+
+	var v SemVer
+	switch x := params[0].(type) {
+	case SemVer:
+		v = x
+	case string:
+		var err error
+		if v, err = ParseSemVer(x); err != nil {
+			return nil, OpExecError(op, err)
+		}
+	default:
+		return nil, ParamTypeError(op, typeSemVer, params[0])
+	}
+
+	c := s.constraint
+	if c == nil {
+		str, ok := params[1].(string)
+		if !ok {
+			return nil, ParamTypeError(op, typeStr, params[1])
+		}
+
+		var err error
+		if c, err = parseVersionConstraint(str); err != nil {
+			return nil, OpExecError(op, err)
+		}
+	}
+	return c.satisfied(v), nil
+}
+
+func precompileVersionConstraint(s string) (Operator, error) {
+	c, err := parseVersionConstraint(s)
+	if err != nil {
+		return nil, err
+	}
+	return versionSatisfies{constraint: c}.execute, nil
+}
+
 func DestructParamsStr2(opName string, params []Value) (a, b string, e error) {
 	if len(params) != 2 {
 		e = ParamsCountError(opName, 2, len(params))
@@ -624,9 +2513,17 @@ func ParamsCountError(opName string, want, got int) error {
 }
 
 func ParamTypeError(opName string, want string, got Value) error {
+	if got == nil {
+		return fmt.Errorf("unexpected param type, operator: %s, expected: %s, got: null", opName, want)
+	}
 	return fmt.Errorf("unexpected param type, operator: %s, expected: %s, got: %+v", opName, want, got)
 }
 
+// errCntRange is the ParamsCountError of the operators with the optional params
+func errCntRange(opName string, min, max, got int) error {
+	return fmt.Errorf("unexpected params count, operator: %s, expected: %d to %d, got: %d", opName, min, max, got)
+}
+
 func errCnt2(m mode, params []Value) error {
 	return ParamsCountError(modeNames[m], 2, len(params))
 }
@@ -635,6 +2532,10 @@ func errTypeInt(m mode, p Value) error {
 	return ParamTypeError(modeNames[m], typeInt, p)
 }
 
+func errTypeNumber(m mode, p Value) error {
+	return ParamTypeError(modeNames[m], typeNumber, p)
+}
+
 func errTypeBool(m mode, p Value) error {
 	return ParamTypeError(modeNames[m], typeBool, p)
 }
@@ -1,6 +1,7 @@
 package eval
 
 import (
+	"math"
 	"testing"
 	"time"
 )
@@ -53,6 +54,29 @@ func TestRegisterOperator(t *testing.T) {
 	}
 	err = RegisterOperator(cc, "add", testOp)
 	assertErrStrContains(t, err, "operator already exist")
+
+	// the registered operator shadows the builtin one, except the reserved ones
+	err = RegisterOperator(cc, "lower", func(_ *Ctx, params []Value) (Value, error) {
+		return "shadowed", nil
+	})
+	assertNil(t, err)
+
+	res, err = Eval(`(concat (lower "A") (upper "b"))`, nil, ExtendConf(cc))
+	assertNil(t, err)
+	assertEquals(t, res, "shadowedB")
+
+	res, err = Eval(`(lower "A")`, nil)
+	assertNil(t, err)
+	assertEquals(t, res, "a")
+
+	// the constant param of the shadowed operator is not precompiled
+	res, err = Eval(`(matches "a" "(")`, nil, RegVarAndOp(map[string]interface{}{
+		"matches": func(_ *Ctx, params []Value) (Value, error) {
+			return true, nil
+		},
+	}))
+	assertNil(t, err)
+	assertEquals(t, res, true)
 }
 
 func TestBuiltinOperators(t *testing.T) {
@@ -69,6 +93,8 @@ func TestBuiltinOperators(t *testing.T) {
 		return v
 	}
 
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+
 	testCases := []struct {
 		op     string
 		params []Value
@@ -115,8 +141,8 @@ func TestBuiltinOperators(t *testing.T) {
 
 		{
 			op:     "add",
-			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			params: []Value{int64(1), 1.0}, // the int param is promoted to float64
+			res:    2.0,
 		},
 
 		// sub
@@ -152,8 +178,8 @@ func TestBuiltinOperators(t *testing.T) {
 
 		{
 			op:     "sub",
-			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			params: []Value{int64(1), 1.0}, // the int param is promoted to float64
+			res:    0.0,
 		},
 
 		// mul
@@ -189,8 +215,8 @@ func TestBuiltinOperators(t *testing.T) {
 
 		{
 			op:     "mul",
-			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			params: []Value{int64(1), 1.0}, // the int param is promoted to float64
+			res:    1.0,
 		},
 
 		// div
@@ -238,8 +264,8 @@ func TestBuiltinOperators(t *testing.T) {
 
 		{
 			op:     "div",
-			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			params: []Value{int64(1), 1.0}, // the int param is promoted to float64
+			res:    1.0,
 		},
 
 		// mod
@@ -287,8 +313,8 @@ func TestBuiltinOperators(t *testing.T) {
 
 		{
 			op:     "mod",
-			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			params: []Value{int64(1), 1.0}, // the int param is promoted to float64
+			res:    0.0,
 		},
 
 		// logic
@@ -425,7 +451,67 @@ func TestBuiltinOperators(t *testing.T) {
 
 		{
 			op:     "eq",
-			params: []Value{1, 1.0},
+			params: []Value{1, 1.0}, // the integers of any width are promoted
+			res:    true,
+		},
+
+		{
+			op:     "eq",
+			params: []Value{uint8(1), int32(1), int64(1)},
+			res:    true,
+		},
+
+		{
+			op:     "eq",
+			params: []Value{[]string{"a", "b"}, []string{"a", "b"}},
+			res:    true,
+		},
+
+		{
+			op:     "eq",
+			params: []Value{[]int64{1, 2}, []Value{int64(1), 2.0}},
+			res:    true,
+		},
+
+		{
+			op:     "eq",
+			params: []Value{[]int64{1, 2}, []int{1, 2, 3}},
+			res:    false,
+		},
+
+		{
+			op:     "eq",
+			params: []Value{[]Value{[]string{"a"}, true}, []Value{[]string{"a"}, true}},
+			res:    true,
+		},
+
+		{
+			op:     "eq",
+			params: []Value{map[string]int64{"a": 1}, map[string]interface{}{"a": 1}},
+			res:    true,
+		},
+
+		{
+			op:     "eq",
+			params: []Value{map[string]int64{"a": 1}, map[string]int64{"b": 1}},
+			res:    false,
+		},
+
+		{
+			op:     "eq",
+			params: []Value{map[int]string{1: "a"}, map[int64]string{1: "a"}},
+			res:    true,
+		},
+
+		{
+			op:     "eq",
+			params: []Value{map[int]string{1: "a"}, map[string]string{"1": "a"}},
+			res:    false,
+		},
+
+		{
+			op:     "eq",
+			params: []Value{[]string{"a"}, "a"},
 			res:    false,
 		},
 
@@ -441,6 +527,30 @@ func TestBuiltinOperators(t *testing.T) {
 			res:    true,
 		},
 
+		{
+			op:     "lt",
+			params: []Value{nil, int64(1)},
+			errMsg: "got: null",
+		},
+
+		{
+			op:     "is_null",
+			params: []Value{nil},
+			res:    true,
+		},
+
+		{
+			op:     "is_null",
+			params: []Value{""},
+			res:    false,
+		},
+
+		{
+			op:     "is_null",
+			params: []Value{nil, nil},
+			errMsg: paramsCntErrMsg,
+		},
+
 		{
 			op:     "eq",
 			params: []Value{false, false},
@@ -483,6 +593,12 @@ func TestBuiltinOperators(t *testing.T) {
 		{
 			op:     "ne",
 			params: []Value{1, 1.0},
+			res:    false,
+		},
+
+		{
+			op:     "ne",
+			params: []Value{[]int64{1, 2}, []int64{2, 1}},
 			res:    true,
 		},
 
@@ -574,8 +690,8 @@ func TestBuiltinOperators(t *testing.T) {
 
 		{
 			op:     "gt",
-			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			params: []Value{int64(1), 1.0}, // the int param is promoted to float64
+			res:    false,
 		},
 
 		// ge
@@ -623,8 +739,8 @@ func TestBuiltinOperators(t *testing.T) {
 
 		{
 			op:     "ge",
-			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			params: []Value{int64(1), 1.0}, // the int param is promoted to float64
+			res:    true,
 		},
 
 		// lt
@@ -672,8 +788,8 @@ func TestBuiltinOperators(t *testing.T) {
 
 		{
 			op:     "lt",
-			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			params: []Value{int64(1), 1.0}, // the int param is promoted to float64
+			res:    false,
 		},
 
 		// le
@@ -721,8 +837,8 @@ func TestBuiltinOperators(t *testing.T) {
 
 		{
 			op:     "le",
-			params: []Value{int64(1), 1.0},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			params: []Value{int64(1), 1.0}, // the int param is promoted to float64
+			res:    true,
 		},
 
 		// between
@@ -774,236 +890,1670 @@ func TestBuiltinOperators(t *testing.T) {
 			errMsg: paramTypeErrMsg, // type of int param should be int64
 		},
 
-		// list
-		// in
+		// float
 		{
-			op:     "in",
-			params: []Value{int64(1), []int64{1, 2, 3}},
+			op:     "add",
+			params: []Value{0.5, 0.25, int64(1)},
+			res:    1.75,
+		},
+
+		{
+			op:     "div",
+			params: []Value{int64(3), 2.0},
+			res:    1.5,
+		},
+
+		{
+			op:     "div",
+			params: []Value{1.5, 0.0},
+			errMsg: "divide by zero",
+		},
+
+		{
+			op:     "mod",
+			params: []Value{5.5, int64(2)},
+			res:    1.5,
+		},
+
+		{
+			op:     "mul",
+			params: []Value{1e300, 1e300},
+			errMsg: "float overflow",
+		},
+
+		{
+			op:     "add",
+			params: []Value{0.5, "1"},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "gt",
+			params: []Value{0.83, 0.82},
 			res:    true,
 		},
 
 		{
-			op:     "in",
-			params: []Value{int64(0), []int64{1, 2, 3}},
+			op:     "le",
+			params: []Value{int64(1), 0.5},
 			res:    false,
 		},
 
 		{
-			op:     "in",
-			params: []Value{"a", []string{"a", "b", "c"}},
+			op:     "lt",
+			params: []Value{0.5, "1"},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "between",
+			params: []Value{0.5, int64(0), 1.0},
 			res:    true,
 		},
 
 		{
-			op:     "in",
-			params: []Value{"abc", []string{"a", "b", "c"}},
+			op:     "between",
+			params: []Value{1.5, int64(0), int64(1)},
 			res:    false,
 		},
 
 		{
-			op:     "in",
-			params: []Value{int64(1), []int64{1, 1, 1}, []int64{2, 2, 2}},
-			errMsg: paramsCntErrMsg,
+			op:     "eq",
+			params: []Value{int64(1), 1.0},
+			res:    true,
 		},
 
 		{
-			op:     "in",
-			params: []Value{[]int64{1, 1, 1}},
-			errMsg: paramsCntErrMsg,
+			op:     "eq",
+			params: []Value{1.0, int64(1), 1.0},
+			res:    true,
+		},
+
+		{
+			op:     "ne",
+			params: []Value{1.5, int64(1)},
+			res:    true,
 		},
 
 		{
 			op:     "in",
-			params: []Value{int64(1), []string{"a", "b", "c"}},
-			errMsg: paramTypeErrMsg, // type of int param should be int64
+			params: []Value{0.5, []float64{0.25, 0.5}},
+			res:    true,
 		},
 
-		// overlap
 		{
-			op:     "overlap",
-			params: []Value{[]int64{0, 1, 2}, []int64{0, -1, -2}},
+			op:     "in",
+			params: []Value{int64(2), []float64{0.5, 2}},
 			res:    true,
 		},
 
 		{
-			op:     "overlap",
-			params: []Value{[]int64{1, 2}, []int64{-1, -2}},
-			res:    false,
+			op:     "in",
+			params: []Value{2.0, []int64{1, 2}},
+			res:    true,
 		},
 
 		{
-			op:     "overlap",
-			params: []Value{[]int64{1, 2}, []int64{}},
-			res:    false,
+			op:     "in",
+			params: []Value{2.5, []string{"a"}},
+			errMsg: paramTypeErrMsg,
 		},
 
 		{
 			op:     "overlap",
-			params: []Value{[]int64{}, []int64{}},
+			params: []Value{[]float64{0.5, 1.5}, []int64{1, 2}},
 			res:    false,
 		},
 
 		{
 			op:     "overlap",
-			params: []Value{[]string{"a", "b", "c"}, []string{"a", "b", "c"}},
+			params: []Value{[]float64{0.5, 1.5}, []float64{1.5}},
 			res:    true,
 		},
 
 		{
 			op:     "overlap",
-			params: []Value{[]string{"a", "b", "c"}, []string{}},
-			res:    false,
+			params: []Value{[]float64{0.5}, []string{"a"}},
+			errMsg: paramTypeErrMsg,
 		},
 
 		{
 			op:     "overlap",
-			params: []Value{[]string{"aa", "bb", "cc"}, []string{"a", "b", "c"}},
-			res:    false,
+			params: []Value{[]Value{int64(1), "a"}, []string{"a"}},
+			res:    true,
 		},
 
 		{
 			op:     "overlap",
-			params: []Value{[]int64{1, 1, 1}, []int64{1, 1, 1}, []int64{2, 2, 2}},
-			errMsg: paramsCntErrMsg,
+			params: []Value{[]int64{2}, []Value{"x", 2.0}},
+			res:    true,
 		},
 
 		{
 			op:     "overlap",
-			params: []Value{[]string{"a", "b", "c"}, []int64{1, 1, 1}},
-			errMsg: paramTypeErrMsg,
+			params: []Value{[]Value{true, "a"}, []Value{false, "b"}},
+			res:    false,
 		},
 
 		{
 			op:     "overlap",
-			params: []Value{int64(1), []string{"a", "b", "c"}},
+			params: []Value{[]Value{int64(1), "a"}, "a"},
 			errMsg: paramTypeErrMsg,
 		},
 
+		// bitwise
 		{
-			op:     "overlap",
-			params: []Value{[]int64{1, 2}, []float64{1, 2}},
-			errMsg: paramTypeErrMsg,
+			op:     "band",
+			params: []Value{int64(0x16), int64(0x14), int64(0x0F)},
+			res:    int64(0x04),
 		},
 
-		// time
-		// date
 		{
-			op:     "date",
-			params: []Value{"2022-05-06"},
-			res:    toTime(defaultDateLayout, "2022-05-06").Unix(),
+			op:     "bor",
+			params: []Value{int64(0x10), int64(0x04), int64(0x01)},
+			res:    int64(0x15),
 		},
+
 		{
-			op:     "date",
-			params: []Value{"2022/05/06", "2006/01/02"}, // set the using layout
-			res:    toTime(defaultDateLayout, "2022-05-06").Unix(),
+			op:     "bxor",
+			params: []Value{int64(0xFF), int64(0x0F)},
+			res:    int64(0xF0),
 		},
+
 		{
-			op:     "date",
-			params: []Value{"2022/05/06"},
-			errMsg: "cannot parse", // should set the layout for custom date format
+			op:     "band",
+			params: []Value{int64(1)},
+			errMsg: paramsCntErrMsg,
 		},
+
 		{
-			op:     "date",
-			params: []Value{int64(20220506)},
+			op:     "bor",
+			params: []Value{int64(1), 1.0},
 			errMsg: paramTypeErrMsg,
 		},
+
 		{
-			op:     "date",
-			params: []Value{"2022-05-06", "2022-05-07", "2022-05-08"},
-			errMsg: paramsCntErrMsg,
+			op:     "shl",
+			params: []Value{int64(1), int64(4), int64(2)},
+			res:    int64(64),
 		},
 
-		// datetime
 		{
-			op:     "datetime",
-			params: []Value{"2022-05-06 03:56:12"},
-			res:    toTime(defaultDatetimeLayout, "2022-05-06 03:56:12").Unix(),
+			op:     "<<",
+			params: []Value{int64(1), int64(64)},
+			res:    int64(0),
 		},
+
 		{
-			op:     "datetime",
-			params: []Value{"03:56:12, 2022/05/06", "15:04:05, 2006/01/02"}, // set the using layout
-			res:    toTime(defaultDatetimeLayout, "2022-05-06 03:56:12").Unix(),
+			op:     "shr",
+			params: []Value{int64(-16), int64(2)},
+			res:    int64(-4),
 		},
+
 		{
-			op:     "datetime",
-			params: []Value{"15:04:05, 2022/05/06"},
-			errMsg: "cannot parse", // should set the layout for custom date format
+			op:     ">>",
+			params: []Value{int64(1), int64(-1)},
+			errMsg: "negative shift count",
 		},
+
 		{
-			op:     "datetime",
-			params: []Value{int64(20220506035612)},
-			errMsg: paramTypeErrMsg,
+			op:     "bnot",
+			params: []Value{int64(0)},
+			res:    int64(-1),
 		},
+
 		{
-			op:     "datetime",
-			params: []Value{"2022-05-06 03:56:12", "2022-05-07 03:56:12", "2022-05-08 03:56:12"},
+			op:     "bnot",
+			params: []Value{int64(1), int64(2)},
 			errMsg: paramsCntErrMsg,
 		},
 
-		// t_time
 		{
-			op:     "t_time",
-			params: []Value{"2022-05-06 03:56:12", "2006-01-02 15:04:05"},
-			res:    toTime(defaultDatetimeLayout, "2022-05-06 03:56:12").Unix(),
+			op:     "bnot",
+			params: []Value{"1"},
+			errMsg: paramTypeErrMsg,
 		},
+
 		{
-			op:     "t_time",
-			params: []Value{"03:56:12, 2022/05/06", "15:04:05, 2006/01/02"}, // set the using layout
-			res:    toTime(defaultDatetimeLayout, "2022-05-06 03:56:12").Unix(),
+			op:     "has_bits",
+			params: []Value{int64(0x16), int64(0x14)},
+			res:    true,
 		},
+
 		{
-			op:     "t_time",
-			params: []Value{"2022-05-06 03:56:12"},
-			errMsg: paramsCntErrMsg,
+			op:     "has_bits",
+			params: []Value{int64(0x16), int64(0x11)},
+			res:    false,
 		},
-		// t_date
+
 		{
-			op:     "t_date",
+			op:     "has_bits",
+			params: []Value{int64(0x16), "0x14"},
+			errMsg: paramTypeErrMsg,
+		},
+
+		// element
+		{
+			op:     "get",
+			params: []Value{map[string]int64{"exp_42": 2}, "exp_42"},
+			res:    int64(2),
+		},
+
+		{
+			op:     "get",
+			params: []Value{map[string]interface{}{"a": 1}, "a"},
+			res:    int64(1),
+		},
+
+		{
+			op:     "get",
+			params: []Value{map[int]string{7: "seven"}, int64(7)},
+			res:    "seven",
+		},
+
+		{
+			op:     "get",
+			params: []Value{map[string]int64{"exp_42": 2}, "exp_1"},
+			errMsg: "key not found",
+		},
+
+		{
+			op:     "get",
+			params: []Value{map[string]int64{"exp_42": 2}, int64(1)},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "get",
+			params: []Value{[]string{"a", "b"}, int64(1)},
+			res:    "b",
+		},
+
+		{
+			op:     "get",
+			params: []Value{[]string{"a", "b"}, int64(2)},
+			errMsg: "index out of range",
+		},
+
+		{
+			op:     "get_or_null",
+			params: []Value{[]string{"a", "b"}, int64(-1)},
+			res:    nil,
+		},
+
+		{
+			op:     "get_or_null",
+			params: []Value{map[string]string{"a": "b"}, "c"},
+			res:    nil,
+		},
+
+		{
+			op:     "get_or_null",
+			params: []Value{nil, "c"},
+			res:    nil,
+		},
+
+		{
+			op:     "get_or_null",
+			params: []Value{"abc", int64(0)},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "nth",
+			params: []Value{[]Value{int64(1), "a"}, int64(1)},
+			res:    "a",
+		},
+
+		{
+			op:     "nth",
+			params: []Value{map[string]int64{"a": 1}, "a"},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "first",
+			params: []Value{[]float64{1.5, 2.5}},
+			res:    1.5,
+		},
+
+		{
+			op:     "last",
+			params: []Value{[]int64{1, 2, 3}},
+			res:    int64(3),
+		},
+
+		{
+			op:     "last",
+			params: []Value{[]int64{}},
+			errMsg: "empty list",
+		},
+
+		// time
+		{
+			op:     "add_duration",
+			params: []Value{int64(1700000000), int64(7 * 24 * 3600)},
+			res:    int64(1700604800),
+		},
+
+		{
+			op:     "add_duration",
+			params: []Value{int64(math.MaxInt64), int64(1)},
+			errMsg: "integer overflow",
+		},
+
+		{
+			op:     "truncate_to_day",
+			params: []Value{int64(1700000000)},
+			res:    int64(1699920000),
+		},
+
+		{
+			op:     "truncate_to_day",
+			params: []Value{int64(-1)},
+			res:    int64(-86400),
+		},
+
+		{
+			op:     "since",
+			params: []Value{"2021-01-01"},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "until",
+			params: []Value{int64(1), int64(2)},
+			errMsg: paramsCntErrMsg,
+		},
+
+		// map
+		{
+			op:     "dict",
+			params: []Value{"US", int64(10), "CA", int64(8)},
+			res:    map[string]int64{"US": 10, "CA": 8},
+		},
+
+		{
+			op:     "dict",
+			params: []Value{"a", int64(1), "b", 1.5},
+			res:    map[string]float64{"a": 1, "b": 1.5},
+		},
+
+		{
+			op:     "dict",
+			params: []Value{"a", int64(1), "b", "x"},
+			res:    map[string]Value{"a": int64(1), "b": "x"},
+		},
+
+		{
+			op:     "dict",
+			params: []Value{},
+			res:    map[string]Value{},
+		},
+
+		{
+			op:     "get",
+			params: []Value{map[string]Value{"a": nil}, "a"},
+			res:    nil,
+		},
+
+		{
+			op:     "dict",
+			params: []Value{"a", int64(1), "a", int64(2)},
+			errMsg: "duplicate map key",
+		},
+
+		{
+			op:     "dict",
+			params: []Value{"a", int64(1), "b"},
+			errMsg: "has no value",
+		},
+
+		{
+			op:     "dict",
+			params: []Value{int64(1), int64(1)},
+			errMsg: paramTypeErrMsg,
+		},
+
+		// math
+		{
+			op:     "abs",
+			params: []Value{int64(-3)},
+			res:    int64(3),
+		},
+
+		{
+			op:     "abs",
+			params: []Value{int64(math.MinInt64)},
+			errMsg: "integer overflow",
+		},
+
+		{
+			op:     "abs",
+			params: []Value{mustDecimal(-1250, 2)},
+			res:    mustDecimal(1250, 2),
+		},
+
+		{
+			op:     "max",
+			params: []Value{int64(3), int64(8), int64(5)},
+			res:    int64(8),
+		},
+
+		{
+			op:     "min",
+			params: []Value{int64(3), 2.5, int64(5)},
+			res:    2.5,
+		},
+
+		{
+			op:     "max",
+			params: []Value{int64(3), 2.5},
+			res:    3.0, // the result is promoted to float
+		},
+
+		{
+			op:     "min",
+			params: []Value{mustDecimal(199, 2), int64(2)},
+			res:    mustDecimal(199, 2),
+		},
+
+		{
+			op:     "min",
+			params: []Value{mustDecimal(199, 2), 2.5},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "max",
+			params: []Value{int64(1)},
+			errMsg: paramsCntErrMsg,
+		},
+
+		{
+			op:     "pow",
+			params: []Value{int64(2), int64(10)},
+			res:    int64(1024),
+		},
+
+		{
+			op:     "pow",
+			params: []Value{int64(-3), int64(3)},
+			res:    int64(-27),
+		},
+
+		{
+			op:     "pow",
+			params: []Value{int64(2), int64(63)},
+			errMsg: "integer overflow",
+		},
+
+		{
+			op:     "pow",
+			params: []Value{int64(2), int64(-1)},
+			res:    0.5,
+		},
+
+		{
+			op:     "pow",
+			params: []Value{10.0, 400.0},
+			errMsg: "float overflow",
+		},
+
+		{
+			op:     "pow",
+			params: []Value{mustDecimal(11, 1), int64(2)},
+			res:    mustDecimal(121, 2),
+		},
+
+		{
+			op:     "pow",
+			params: []Value{mustDecimal(11, 1), 0.5},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "sqrt",
+			params: []Value{int64(16)},
+			res:    4.0,
+		},
+
+		{
+			op:     "sqrt",
+			params: []Value{-1.0},
+			errMsg: "square root of negative number",
+		},
+
+		{
+			op:     "round",
+			params: []Value{2.5},
+			res:    3.0,
+		},
+
+		{
+			op:     "round",
+			params: []Value{3.14159, int64(2)},
+			res:    3.14,
+		},
+
+		{
+			op:     "round",
+			params: []Value{mustDecimal(1255, 3), int64(2)},
+			res:    mustDecimal(126, 2),
+		},
+
+		{
+			op:     "round",
+			params: []Value{int64(7), int64(2)},
+			res:    int64(7),
+		},
+
+		{
+			op:     "round",
+			params: []Value{2.5, int64(19)},
+			errMsg: "digits out of range",
+		},
+
+		{
+			op:     "round",
+			params: []Value{2.5, int64(1), int64(2)},
+			errMsg: "expected: 1 to 2, got: 3",
+		},
+
+		{
+			op:     "floor",
+			params: []Value{-2.5},
+			res:    -3.0,
+		},
+
+		{
+			op:     "floor",
+			params: []Value{mustDecimal(-1251, 3), int64(2)},
+			res:    mustDecimal(-126, 2),
+		},
+
+		{
+			op:     "ceil",
+			params: []Value{mustDecimal(1251, 3), int64(2)},
+			res:    mustDecimal(126, 2),
+		},
+
+		{
+			op:     "ceil",
+			params: []Value{"2.5"},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "clamp",
+			params: []Value{int64(120), int64(0), int64(100)},
+			res:    int64(100),
+		},
+
+		{
+			op:     "clamp",
+			params: []Value{int64(-5), 0.5, int64(100)},
+			res:    0.5,
+		},
+
+		{
+			op:     "clamp",
+			params: []Value{int64(5), int64(10), int64(0)},
+			errMsg: "invalid range",
+		},
+
+		{
+			op:     "sign",
+			params: []Value{-2.5},
+			res:    int64(-1),
+		},
+
+		{
+			op:     "sign",
+			params: []Value{mustDecimal(0, 2)},
+			res:    int64(0),
+		},
+
+		// string ordering
+		{
+			op:     "lt",
+			params: []Value{"alice", "bob"},
+			res:    true,
+		},
+
+		{
+			op:     "gt",
+			params: []Value{"a", "B"}, // strings are compared byte-wise
+			res:    true,
+		},
+
+		{
+			op:     "ge",
+			params: []Value{"", ""},
+			res:    true,
+		},
+
+		{
+			op:     "le",
+			params: []Value{"abc", "ab"},
+			res:    false,
+		},
+
+		{
+			op:     "lt",
+			params: []Value{"1", int64(2)},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "gt",
+			params: []Value{[]string{"a"}, []string{"b"}},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "between",
+			params: []Value{"en-US", "en", "es"},
+			res:    true,
+		},
+
+		{
+			op:     "between",
+			params: []Value{"fr", "en", "es"},
+			res:    false,
+		},
+
+		{
+			op:     "between",
+			params: []Value{"en", "en", int64(1)},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "gt",
+			params: []Value{math.NaN(), 0.5},
+			errMsg: "NaN can not be ordered",
+		},
+
+		// string
+		{
+			op:     "concat",
+			params: []Value{"a", "b", "c"},
+			res:    "abc",
+		},
+
+		{
+			op:     "concat",
+			params: []Value{"a"},
+			errMsg: paramsCntErrMsg,
+		},
+
+		{
+			op:     "concat",
+			params: []Value{"a", int64(1)},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "len",
+			params: []Value{"héllo"},
+			res:    int64(5),
+		},
+
+		{
+			op:     "length",
+			params: []Value{[]int64{1, 2}},
+			res:    int64(2),
+		},
+
+		{
+			op:     "len",
+			params: []Value{int64(1)},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "lower",
+			params: []Value{"HeLLo"},
+			res:    "hello",
+		},
+
+		{
+			op:     "to_upper",
+			params: []Value{"HeLLo"},
+			res:    "HELLO",
+		},
+
+		{
+			op:     "upper",
+			params: []Value{"a", "b"},
+			errMsg: paramsCntErrMsg,
+		},
+
+		{
+			op:     "trim",
+			params: []Value{"  a b \t"},
+			res:    "a b",
+		},
+
+		{
+			op:     "trim",
+			params: []Value{"--a-b_", "-_"},
+			res:    "a-b",
+		},
+
+		{
+			op:     "trim",
+			params: []Value{int64(1)},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "trim",
+			params: []Value{"hello", "h", "o"},
+			errMsg: "expected: 1 to 2, got: 3",
+		},
+
+		{
+			op:     "contains",
+			params: []Value{"hello", "ell"},
+			res:    true,
+		},
+
+		{
+			op:     "starts_with",
+			params: []Value{"hello", "he"},
+			res:    true,
+		},
+
+		{
+			op:     "has_suffix",
+			params: []Value{"hello", "he"},
+			res:    false,
+		},
+
+		{
+			op:     "ends_with",
+			params: []Value{"hello", int64(1)},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "substr",
+			params: []Value{"héllo", int64(1), int64(3)},
+			res:    "éll",
+		},
+
+		{
+			op:     "substring",
+			params: []Value{"hello", int64(3)},
+			res:    "lo",
+		},
+
+		{
+			op:     "substr",
+			params: []Value{"hello", int64(3), int64(10)},
+			res:    "lo",
+		},
+
+		{
+			op:     "substr",
+			params: []Value{"hello", int64(10)},
+			res:    "",
+		},
+
+		{
+			op:     "substr",
+			params: []Value{"hello", int64(-1)},
+			errMsg: "negative index",
+		},
+
+		{
+			op:     "substr",
+			params: []Value{"hello", "1"},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "substr",
+			params: []Value{"hello"},
+			errMsg: "expected: 2 to 3, got: 1",
+		},
+
+		{
+			op:     "index_of",
+			params: []Value{"héllo", "l"},
+			res:    int64(2),
+		},
+
+		{
+			op:     "index_of",
+			params: []Value{"hello", "x"},
+			res:    int64(-1),
+		},
+
+		{
+			op:     "replace",
+			params: []Value{"a-b-c", "-", "+"},
+			res:    "a+b+c",
+		},
+
+		{
+			op:     "replace",
+			params: []Value{"a-b-c", "-"},
+			errMsg: paramsCntErrMsg,
+		},
+
+		{
+			op:     "split",
+			params: []Value{"a,b,c", ","},
+			res:    []string{"a", "b", "c"},
+		},
+
+		{
+			op:     "split",
+			params: []Value{[]string{"a"}, ","},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "join",
+			params: []Value{[]string{"a", "b"}, ", "},
+			res:    "a, b",
+		},
+
+		{
+			op:     "join",
+			params: []Value{[]int64{1, 2}, ","},
+			errMsg: paramTypeErrMsg,
+		},
+
+		// regex
+		{
+			op:     "matches",
+			params: []Value{"a@corp.com", `.*@corp\.com$`},
+			res:    true,
+		},
+
+		{
+			op:     "matches",
+			params: []Value{"a@corp.org", `.*@corp\.com$`},
+			res:    false,
+		},
+
+		{
+			op:     "matches",
+			params: []Value{"abc", "("},
+			errMsg: "missing closing )",
+		},
+
+		{
+			op:     "matches",
+			params: []Value{int64(1), "a"},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "matches",
+			params: []Value{"abc"},
+			errMsg: paramsCntErrMsg,
+		},
+
+		{
+			op:     "find",
+			params: []Value{"order 42 and 7", `\d+`},
+			res:    "42",
+		},
+
+		{
+			op:     "find",
+			params: []Value{"order", `\d+`},
+			res:    "",
+		},
+
+		{
+			op:     "extract",
+			params: []Value{"user=alice;id=3", `user=(\w+)`},
+			res:    "alice",
+		},
+
+		{
+			op:     "extract",
+			params: []Value{"2023-06-01", `(\d+)-(\d+)`, int64(2)},
+			res:    "06",
+		},
+
+		{
+			op:     "extract",
+			params: []Value{"2023-06-01", `(\d+)-(\d+)`, int64(0)},
+			res:    "2023-06",
+		},
+
+		{
+			op:     "extract",
+			params: []Value{"abc", `(\d+)`},
+			res:    "",
+		},
+
+		{
+			op:     "extract",
+			params: []Value{"order 123", `\d+`},
+			res:    "123",
+		},
+
+		{
+			op:     "extract",
+			params: []Value{"order 123", `\d+`, int64(1)},
+			errMsg: "capturing group out of range",
+		},
+
+		{
+			op:     "extract",
+			params: []Value{"2023", `(\d+)`, int64(2)},
+			errMsg: "capturing group out of range",
+		},
+
+		{
+			op:     "extract",
+			params: []Value{"2023", `(\d+)`, "1"},
+			errMsg: paramTypeErrMsg,
+		},
+
+		// decimal
+		{
+			op:     "add",
+			params: []Value{mustDecimal(1250, 2), mustDecimal(5, 1), int64(1)},
+			res:    mustDecimal(1400, 2),
+		},
+
+		{
+			op:     "sub",
+			params: []Value{mustDecimal(10, 1), mustDecimal(3, 2)},
+			res:    mustDecimal(97, 2),
+		},
+
+		{
+			op:     "mul",
+			params: []Value{mustDecimal(1999, 2), int64(3)},
+			res:    mustDecimal(5997, 2),
+		},
+
+		{
+			op:     "div",
+			params: []Value{mustDecimal(10, 0), int64(3)},
+			res:    mustDecimal(333333333, 8),
+		},
+
+		{
+			op:     "div",
+			params: []Value{mustDecimal(2, 0), int64(3)},
+			res:    mustDecimal(66666667, 8), // rounds half up by default
+		},
+
+		{
+			op:     "div",
+			params: []Value{mustDecimal(1, 2), mustDecimal(0, 0)},
+			errMsg: "divide by zero",
+		},
+
+		{
+			op:     "mod",
+			params: []Value{mustDecimal(1050, 2), int64(4)},
+			res:    mustDecimal(250, 2),
+		},
+
+		{
+			op:     "mul",
+			params: []Value{mustDecimal(9223372036854775807, 0), int64(2)},
+			errMsg: "decimal overflow",
+		},
+
+		{
+			op:     "add",
+			params: []Value{mustDecimal(1, 1), 0.5}, // float params are not converted to decimal
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "add",
+			params: []Value{0.5, mustDecimal(1, 1)},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "lt",
+			params: []Value{mustDecimal(1250, 2), mustDecimal(13, 0)},
+			res:    true,
+		},
+
+		{
+			op:     "ge",
+			params: []Value{int64(12), mustDecimal(1200, 2)},
+			res:    true,
+		},
+
+		{
+			op:     "gt",
+			params: []Value{mustDecimal(1, 0), 0.5},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "between",
+			params: []Value{mustDecimal(1250, 2), int64(10), mustDecimal(125, 1)},
+			res:    true,
+		},
+
+		{
+			op:     "between",
+			params: []Value{mustDecimal(1251, 2), int64(10), mustDecimal(125, 1)},
+			res:    false,
+		},
+
+		{
+			op:     "eq",
+			params: []Value{mustDecimal(1250, 2), mustDecimal(125, 1)},
+			res:    true,
+		},
+
+		{
+			op:     "eq",
+			params: []Value{int64(3), mustDecimal(300, 2)},
+			res:    true,
+		},
+
+		{
+			op:     "ne",
+			params: []Value{mustDecimal(5, 1), 0.5},
+			res:    true,
+		},
+
+		{
+			op:     "decimal",
+			params: []Value{"-12.50"},
+			res:    mustDecimal(-1250, 2),
+		},
+
+		{
+			op:     "decimal",
+			params: []Value{int64(7)},
+			res:    mustDecimal(7, 0),
+		},
+
+		{
+			op:     "decimal",
+			params: []Value{"1.2.3"},
+			errMsg: "invalid decimal",
+		},
+
+		{
+			op:     "decimal",
+			params: []Value{"99999999999999999999"},
+			errMsg: "decimal overflow",
+		},
+
+		{
+			op:     "decimal",
+			params: []Value{1.5},
+			errMsg: paramTypeErrMsg,
+		},
+
+		// list
+		// in
+		{
+			op:     "in",
+			params: []Value{int64(1), []int64{1, 2, 3}},
+			res:    true,
+		},
+
+		{
+			op:     "in",
+			params: []Value{int64(0), []int64{1, 2, 3}},
+			res:    false,
+		},
+
+		{
+			op:     "in",
+			params: []Value{"a", []string{"a", "b", "c"}},
+			res:    true,
+		},
+
+		{
+			op:     "in",
+			params: []Value{"abc", []string{"a", "b", "c"}},
+			res:    false,
+		},
+
+		{
+			op:     "in",
+			params: []Value{int64(1), []int64{1, 1, 1}, []int64{2, 2, 2}},
+			errMsg: paramsCntErrMsg,
+		},
+
+		{
+			op:     "in",
+			params: []Value{[]int64{1, 1, 1}},
+			errMsg: paramsCntErrMsg,
+		},
+
+		{
+			op:     "in",
+			params: []Value{int64(1), []string{"a", "b", "c"}},
+			errMsg: paramTypeErrMsg, // type of int param should be int64
+		},
+
+		{
+			op:     "in",
+			params: []Value{true, []Value{true, false}},
+			res:    true,
+		},
+
+		{
+			op:     "in",
+			params: []Value{"x", []Value{"x", int64(1)}},
+			res:    true,
+		},
+
+		{
+			op:     "in",
+			params: []Value{1.0, []Value{"x", int64(1)}},
+			res:    true,
+		},
+
+		{
+			op:     "in",
+			params: []Value{[]int64{2}, []Value{[]int64{1}, []int64{2}}},
+			res:    true,
+		},
+
+		{
+			op:     "in",
+			params: []Value{nil, []Value{"x", int64(1)}},
+			res:    false,
+		},
+
+		// overlap
+		{
+			op:     "overlap",
+			params: []Value{[]int64{0, 1, 2}, []int64{0, -1, -2}},
+			res:    true,
+		},
+
+		{
+			op:     "overlap",
+			params: []Value{[]int64{1, 2}, []int64{-1, -2}},
+			res:    false,
+		},
+
+		{
+			op:     "overlap",
+			params: []Value{[]int64{1, 2}, []int64{}},
+			res:    false,
+		},
+
+		{
+			op:     "overlap",
+			params: []Value{[]int64{}, []int64{}},
+			res:    false,
+		},
+
+		{
+			op:     "overlap",
+			params: []Value{[]string{"a", "b", "c"}, []string{"a", "b", "c"}},
+			res:    true,
+		},
+
+		{
+			op:     "overlap",
+			params: []Value{[]string{"a", "b", "c"}, []string{}},
+			res:    false,
+		},
+
+		{
+			op:     "overlap",
+			params: []Value{[]string{"aa", "bb", "cc"}, []string{"a", "b", "c"}},
+			res:    false,
+		},
+
+		{
+			op:     "overlap",
+			params: []Value{[]int64{1, 1, 1}, []int64{1, 1, 1}, []int64{2, 2, 2}},
+			errMsg: paramsCntErrMsg,
+		},
+
+		{
+			op:     "overlap",
+			params: []Value{[]string{"a", "b", "c"}, []int64{1, 1, 1}},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "overlap",
+			params: []Value{int64(1), []string{"a", "b", "c"}},
+			errMsg: paramTypeErrMsg,
+		},
+
+		{
+			op:     "overlap",
+			params: []Value{[]int64{1, 2}, []float64{1, 2}},
+			res:    true,
+		},
+
+		// time
+		// date
+		{
+			op:     "date",
+			params: []Value{"2022-05-06"},
+			res:    toTime(defaultDateLayout, "2022-05-06"),
+		},
+		{
+			op:     "date",
+			params: []Value{"2022/05/06", "2006/01/02"}, // set the using layout
+			res:    toTime(defaultDateLayout, "2022-05-06"),
+		},
+		{
+			op:     "date",
+			params: []Value{"2022/05/06"},
+			errMsg: "cannot parse", // should set the layout for custom date format
+		},
+		{
+			op:     "date",
+			params: []Value{int64(20220506)},
+			errMsg: paramTypeErrMsg,
+		},
+		{
+			op:     "date",
+			params: []Value{"2022-05-06", "2006-01-02", "Asia/Tokyo", "2022-05-08"},
+			errMsg: "expected: 1 to 3, got: 4",
+		},
+		{
+			op:     "date",
+			params: []Value{"2022-05-06", "2006-01-02", "Asia/Tokyo"},
+			res:    time.Date(2022, 5, 6, 0, 0, 0, 0, tokyo),
+		},
+		{
+			op:     "date",
+			params: []Value{"2022-05-06", "2006-01-02", "Mars/Olympus"},
+			errMsg: "unknown time zone",
+		},
+
+		// datetime
+		{
+			op:     "datetime",
+			params: []Value{"2022-05-06 03:56:12"},
+			res:    toTime(defaultDatetimeLayout, "2022-05-06 03:56:12"),
+		},
+		{
+			op:     "datetime",
+			params: []Value{"03:56:12, 2022/05/06", "15:04:05, 2006/01/02"}, // set the using layout
+			res:    toTime(defaultDatetimeLayout, "2022-05-06 03:56:12"),
+		},
+		{
+			op:     "datetime",
+			params: []Value{"15:04:05, 2022/05/06"},
+			errMsg: "cannot parse", // should set the layout for custom date format
+		},
+		{
+			op:     "datetime",
+			params: []Value{int64(20220506035612)},
+			errMsg: paramTypeErrMsg,
+		},
+		{
+			op:     "datetime",
+			params: []Value{"2022-05-06 03:56:12", "2006-01-02 15:04:05", "UTC", "2022-05-08 03:56:12"},
+			errMsg: "expected: 1 to 3, got: 4",
+		},
+		{
+			op:     "datetime",
+			params: []Value{"2022-05-06 03:56:12.5", "2006-01-02 15:04:05", "Asia/Tokyo"},
+			res:    time.Date(2022, 5, 6, 3, 56, 12, 500_000_000, tokyo),
+		},
+		{
+			op:     "datetime",
+			params: []Value{"2022-05-06T03:56:12+09:00", time.RFC3339, "Asia/Tokyo"},
+			res:    time.Date(2022, 5, 6, 3, 56, 12, 0, tokyo),
+		},
+
+		// t_time
+		{
+			op:     "t_time",
+			params: []Value{"2022-05-06 03:56:12", "2006-01-02 15:04:05"},
+			res:    toTime(defaultDatetimeLayout, "2022-05-06 03:56:12"),
+		},
+		{
+			op:     "t_time",
+			params: []Value{"03:56:12, 2022/05/06", "15:04:05, 2006/01/02"}, // set the using layout
+			res:    toTime(defaultDatetimeLayout, "2022-05-06 03:56:12"),
+		},
+		{
+			op:     "t_time",
+			params: []Value{"2022-05-06 03:56:12"},
+			errMsg: paramsCntErrMsg,
+		},
+		// t_date
+		{
+			op:     "t_date",
+			params: []Value{"2022-05-06", "2006-01-02"},
+			res:    toTime(defaultDateLayout, "2022-05-06"),
+		},
+		{
+			op:     "t_date",
+			params: []Value{"2022/05/06", "2006/01/02"}, // set the using layout
+			res:    toTime(defaultDateLayout, "2022-05-06"),
+		},
+		{
+			op:     "t_date",
+			params: []Value{"2022-05-06"},
+			errMsg: paramsCntErrMsg,
+		},
+
+		// td_time
+		{
+			op:     "td_time",
+			params: []Value{"2022-05-06 03:56:12"},
+			res:    toTime(defaultDatetimeLayout, "2022-05-06 03:56:12"),
+		},
+		{
+			op:     "td_time",
+			params: []Value{"03:56:12, 2022/05/06"}, // set the using layout
+			errMsg: "cannot parse",
+		},
+		{
+			op:     "td_time",
+			params: []Value{"2022-05-06 03:56:12", "2006-01-02 15:04:05"},
+			errMsg: paramsCntErrMsg,
+		},
+
+		// td_date
+		{
+			op:     "td_date",
+			params: []Value{"2022-05-06"},
+			res:    toTime(defaultDateLayout, "2022-05-06"),
+		},
+		{
+			op:     "td_date",
+			params: []Value{"2022/05/06"}, // set the using layout
+			errMsg: "cannot parse",
+		},
+		{
+			op:     "td_date",
 			params: []Value{"2022-05-06", "2006-01-02"},
-			res:    toTime(defaultDateLayout, "2022-05-06").Unix(),
+			errMsg: paramsCntErrMsg,
+		},
+
+		// in_tz
+		{
+			op:     "in_tz",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-06 03:56:12"), "Asia/Tokyo"},
+			res:    time.Date(2022, 5, 6, 12, 56, 12, 0, tokyo),
+		},
+		{
+			op:     "in_tz",
+			params: []Value{int64(1651809372), "Asia/Tokyo"},
+			res:    time.Date(2022, 5, 6, 12, 56, 12, 0, tokyo),
+		},
+		{
+			op:     "in_tz",
+			params: []Value{"2022-05-06 03:56:12", "Asia/Tokyo"},
+			errMsg: paramTypeErrMsg,
+		},
+		{
+			op:     "in_tz",
+			params: []Value{int64(1651809372), "Asia/Atlantis"},
+			errMsg: "unknown time zone",
+		},
+		{
+			op:     "sub",
+			params: []Value{time.Date(2022, 5, 6, 3, 56, 12, 0, time.UTC), int64(1651809372 - 60)},
+			res:    int64(60),
+		},
+		{
+			op:     "mul",
+			params: []Value{time.Date(2022, 5, 6, 3, 56, 12, 0, time.UTC), int64(2)},
+			errMsg: paramTypeErrMsg,
+		},
+		{
+			op:     "gt",
+			params: []Value{int64(1651809372), time.Date(2022, 5, 6, 3, 56, 11, 0, time.UTC)},
+			res:    true,
+		},
+		{
+			op:     "from_unix",
+			params: []Value{int64(1651809372)},
+			res:    time.Date(2022, 5, 6, 3, 56, 12, 0, time.UTC),
+		},
+		{
+			op:     "from_unix",
+			params: []Value{int64(1651809372), "Asia/Tokyo"},
+			res:    time.Date(2022, 5, 6, 12, 56, 12, 0, tokyo),
 		},
 		{
-			op:     "t_date",
-			params: []Value{"2022/05/06", "2006/01/02"}, // set the using layout
-			res:    toTime(defaultDateLayout, "2022-05-06").Unix(),
+			op:     "from_unix_ms",
+			params: []Value{int64(1651809372_250)},
+			res:    time.Date(2022, 5, 6, 3, 56, 12, 250_000_000, time.UTC),
 		},
 		{
-			op:     "t_date",
-			params: []Value{"2022-05-06"},
+			op:     "from_unix_ms",
+			params: []Value{1651809372.25},
+			errMsg: paramTypeErrMsg,
+		},
+		{
+			op:     "from_unix",
+			params: []Value{int64(1651809372), "Asia/Tokyo", "UTC"},
+			errMsg: paramsCntErrMsg,
+		},
+		{
+			op:     "in_tz",
+			params: []Value{int64(1651809372)},
 			errMsg: paramsCntErrMsg,
 		},
 
-		// td_time
+		// calendar
 		{
-			op:     "td_time",
+			op:     "year",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-06 03:56:12")},
+			res:    int64(2022),
+		},
+		{
+			op:     "month",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-06 03:56:12")},
+			res:    int64(5),
+		},
+		{
+			op:     "day",
+			params: []Value{int64(1651809372)}, // the Unix seconds are in UTC
+			res:    int64(6),
+		},
+		{
+			op:     "weekday",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-06 03:56:12")},
+			res:    int64(time.Friday),
+		},
+		{
+			op:     "hour",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-06 03:56:12"), "Asia/Tokyo"},
+			res:    int64(12),
+		},
+		{
+			op:     "minute",
+			params: []Value{time.Date(2022, 5, 6, 3, 56, 12, 0, tokyo)},
+			res:    int64(56),
+		},
+		{
+			op:     "day_of_year",
+			params: []Value{toTime(defaultDateLayout, "2022-12-31")},
+			res:    int64(365),
+		},
+		{
+			op:     "hour",
 			params: []Value{"2022-05-06 03:56:12"},
-			res:    toTime(defaultDatetimeLayout, "2022-05-06 03:56:12").Unix(),
+			errMsg: paramTypeErrMsg,
 		},
 		{
-			op:     "td_time",
-			params: []Value{"03:56:12, 2022/05/06"}, // set the using layout
-			errMsg: "cannot parse",
+			op:     "hour",
+			params: []Value{int64(1651809372), "Asia/Atlantis"},
+			errMsg: "unknown time zone",
 		},
 		{
-			op:     "td_time",
-			params: []Value{"2022-05-06 03:56:12", "2006-01-02 15:04:05"},
-			errMsg: paramsCntErrMsg,
+			op:     "hour",
+			params: []Value{int64(1651809372), "Asia/Tokyo", "UTC"},
+			errMsg: "expected: 1 to 2, got: 3",
+		},
+		{
+			op:     "weekday",
+			params: []Value{},
+			errMsg: "expected: 1 to 2, got: 0",
 		},
 
-		// td_date
+		// in_schedule
 		{
-			op:     "td_date",
-			params: []Value{"2022-05-06"},
-			res:    toTime(defaultDateLayout, "2022-05-06").Unix(),
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-06 09:00:00"), "MON-FRI 09:00-17:00"},
+			res:    true,
 		},
 		{
-			op:     "td_date",
-			params: []Value{"2022/05/06"}, // set the using layout
-			errMsg: "cannot parse",
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-06 17:00:00"), "MON-FRI 09:00-17:00"},
+			res:    false, // the end of the clock range is exclusive
 		},
 		{
-			op:     "td_date",
-			params: []Value{"2022-05-06", "2006-01-02"},
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-06 03:56:12"), "mon-fri 09:00-17:00", "Asia/Tokyo"},
+			res:    true,
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-07 10:00:00"), "MON-FRI 09:00-17:00"},
+			res:    false,
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-07 10:00:00"), "SAT,SUN"},
+			res:    true,
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-08 23:30:00"), "FRI-MON 22:00-02:00"},
+			res:    true,
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-10 01:00:00"), "MON 22:00-02:00"},
+			res:    true, // the after midnight part of Monday night
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-09 01:00:00"), "MON 22:00-02:00"},
+			res:    false, // the after midnight part of Sunday night
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-09 22:30:00"), "MON 22:00-02:00"},
+			res:    true,
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDatetimeLayout, "2023-01-01 01:00:00"), "DEC31 22:00-02:00"},
+			res:    true,
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-09 01:00:00"), "MON 09:00-17:00,22:00-02:00"},
+			res:    false,
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDateLayout, "2022-05-02"), "MON#1"},
+			res:    true,
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDateLayout, "2022-05-09"), "MON#1"},
+			res:    false,
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDateLayout, "2022-05-30"), "MON#L"},
+			res:    true,
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDateLayout, "2022-01-05"), "DEC20-JAN05"},
+			res:    true,
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDateLayout, "2022-01-06"), "DEC20-JAN05"},
+			res:    false,
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDateLayout, "2022-08-31"), "JUN-AUG,DEC25"},
+			res:    true,
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{toTime(defaultDatetimeLayout, "2022-05-06 12:30:00"), "MAY 09:00-12:00,13:00-24:00 FRI"},
+			res:    false,
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{int64(1651809372), "MON-FRY"},
+			errMsg: "unknown weekday FRY",
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{int64(1651809372), "FEB30"},
+			errMsg: "invalid date FEB30",
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{int64(1651809372), "09:00-25:00"},
+			errMsg: "invalid clock 25:00",
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{int64(1651809372)},
+			errMsg: "expected: 2 to 3, got: 1",
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{int64(1651809372), "MON", "UTC", "UTC"},
+			errMsg: "expected: 2 to 3, got: 4",
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{int64(1651809372), "MON#6"},
+			errMsg: "invalid nth weekday MON#6",
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{int64(1651809372), "MON#-1"},
+			errMsg: "invalid nth weekday MON#-1",
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{int64(1651809372), "MON#-3"},
+			errMsg: "invalid nth weekday MON#-3",
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{int64(1651809372), "MON TUE"},
+			errMsg: "duplicate weekdays field",
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{int64(1651809372), " "},
+			errMsg: "empty schedule",
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{"2022-05-06", "MON"},
+			errMsg: paramTypeErrMsg,
+		},
+		{
+			op:     "in_schedule",
+			params: []Value{int64(1651809372)},
 			errMsg: paramsCntErrMsg,
 		},
 
@@ -1141,6 +2691,260 @@ func TestBuiltinOperators(t *testing.T) {
 			params: []Value{},
 			errMsg: paramsCntErrMsg,
 		},
+
+		// semver
+		{
+			op:     "semver",
+			params: []Value{"2.3.4-beta.1+abc"},
+			res:    SemVer{Major: 2, Minor: 3, Patch: 4, Pre: "beta.1", Build: "abc"},
+		},
+		{
+			op:     "semver",
+			params: []Value{"10000.0.0-rc-1"},
+			res:    SemVer{Major: 10000, Pre: "rc-1"},
+		},
+		{
+			op:     "to_semver",
+			params: []Value{SemVer{Major: 1}},
+			res:    SemVer{Major: 1},
+		},
+		{
+			op:     "semver",
+			params: []Value{"2.3"},
+			errMsg: "invalid semver: 2.3",
+		},
+		{
+			op:     "semver",
+			params: []Value{"01.2.3"},
+			errMsg: "invalid semver: 01.2.3",
+		},
+		{
+			op:     "semver",
+			params: []Value{"1.2.3-beta.01"},
+			errMsg: "invalid semver: 1.2.3-beta.01",
+		},
+		{
+			op:     "semver",
+			params: []Value{"1.2.3+"},
+			errMsg: "invalid semver: 1.2.3+",
+		},
+		{
+			op:     "semver",
+			params: []Value{"1.2.99999999999999999999"},
+			errMsg: "value out of range",
+		},
+		{
+			op:     "semver",
+			params: []Value{"1.2.x"},
+			errMsg: "invalid semver: 1.2.x",
+		},
+		{
+			op:     "semver",
+			params: []Value{int64(1)},
+			errMsg: paramTypeErrMsg,
+		},
+		{
+			op:     "semver",
+			params: []Value{},
+			errMsg: paramsCntErrMsg,
+		},
+
+		// version_satisfies
+		{
+			op:     "version_satisfies",
+			params: []Value{"2.3.0", ">=2.3.0 <3.0.0 || ^3.1"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"3.0.5", ">=2.3.0 <3.0.0 || ^3.1"},
+			res:    false,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"3.9.0", ">=2.3.0 <3.0.0 || ^3.1"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"4.0.0-alpha", "^3.1"},
+			res:    false,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"0.2.9", "^0.2.3"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"0.3.0", "^0.2.3"},
+			res:    false,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"0.0.4", "^0.0.3"},
+			res:    false,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.2.9", "~1.2.3"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.3.0", "~1.2.3"},
+			res:    false,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"2.3.99", "2.3"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"2.4.0-beta", "<=2.3"},
+			res:    false,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"2.4.0", "> 2.3"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.0.0-beta.11", ">1.0.0-beta.2 <1.0.0-rc.1"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.0.0-alpha.beta", ">1.0.0-alpha.1 <1.0.0-alpha"},
+			res:    false,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.0.0+build.7", "=1.0.0"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.0.0", "!=1.0.0"},
+			res:    false,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{SemVer{Major: 5}, "*"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.2.3-rc.1", "<1.2.3"},
+			res:    false, // the pre-releases are only matched by the comparators of the same pre-release version
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.2.3-rc.1", "<1.2.3-rc.2"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"3.0.0-beta.1", ">=2.3.0 <3.0.0"},
+			res:    false,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"2.4.0-alpha", ">2.3"},
+			res:    false, // the synthetic bound 2.4.0-0 of >2.3 does not allow the pre-releases
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"3.0.0-beta.1", ">2"},
+			res:    false,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"2.4.0", ">2.3"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"2.3.5-rc.1", "<2.4 || ^2.3"},
+			res:    false,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.2.3-beta.5", "~1.2.3-beta.2"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"3.0.0-beta.2", ">=3.0.0-beta.1"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"3.1.0-beta.1", ">=3.0.0-beta.1"},
+			res:    false,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"3.1.0-beta.1", ">=3.0.0-beta.1 || >=3.1.0-alpha"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.2.3", ">=1.2.3 ||"},
+			errMsg: `invalid version constraint ">=1.2.3 ||": empty range`,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.2.3", ">=1.2.x"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"2.9.1", "2.x"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"2.4.0", "2.3.*"},
+			res:    false,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"2.3.7", "2.3.* || 3.X.x"},
+			res:    true,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.2.3", "1.x.3"},
+			errMsg: `invalid version constraint "1.x.3": invalid semver: 1.x.3`,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.2.3", "1.2.x-beta"},
+			errMsg: "invalid semver: 1.2.x-beta",
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.2.3", "!=1.2"},
+			errMsg: "invalid comparator !=1.2",
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.2", "^1"},
+			errMsg: "invalid semver: 1.2",
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{int64(1_0002_0003), "^1"},
+			errMsg: paramTypeErrMsg,
+		},
+		{
+			op:     "version_satisfies",
+			params: []Value{"1.2.3"},
+			errMsg: paramsCntErrMsg,
+		},
 	}
 
 	for _, c := range testCases {
@@ -0,0 +1,179 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Ordering compares two values of the same type, it returns
+// a negative number if a < b, zero if a == b, a positive number if a > b
+type Ordering func(a, b Value) int
+
+type Collation int
+
+const (
+	CollationBinary          Collation = iota // compares strings byte-wise, e.g. "B" < "a"
+	CollationCaseInsensitive                  // compares strings ignoring case, e.g. "a" < "B", "a" == "A"
+)
+
+// RegisterOrdering registers the ordering of the type T, so the values of T
+// can be compared by the gt, lt, ge, le and between operators
+func RegisterOrdering[T any](cc *Config, cmp func(a, b T) int) error {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() == reflect.Interface {
+		return fmt.Errorf("ordering of interface type is not supported %s", typ)
+	}
+
+	zero := reflect.Zero(typ).Interface()
+	switch zero.(type) {
+	case int64, float64, Decimal, string, time.Time, SemVer:
+		return fmt.Errorf("ordering already exist %s", typ)
+	}
+
+	// the values of some types are converted by unifyType before the comparison, e.g. int32 to int64
+	if unified := unifyType(zero); reflect.TypeOf(unified) != typ {
+		return fmt.Errorf("ordering of type %s is not supported, its values are converted to %T", typ, unified)
+	}
+
+	if _, exist := cc.Orderings[typ]; exist {
+		return fmt.Errorf("ordering already exist %s", typ)
+	}
+
+	if cc.Orderings == nil {
+		cc.Orderings = make(map[reflect.Type]Ordering)
+	}
+
+	cc.Orderings[typ] = func(a, b Value) int {
+		return cmp(a.(T), b.(T))
+	}
+	return nil
+}
+
+// ordering dispatches the comparison by the types of the params,
+// the builtin types are numbers (int64, float64 and Decimal), strings, times and semantic versions,
+// other types are compared by the orderings registered to the config
+type ordering struct {
+	collation Collation
+	types     map[reflect.Type]Ordering
+}
+
+func (o ordering) compare(m mode, a, b Value) (int64, error) {
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, errTypeStr(m, b)
+		}
+		if o.collation == CollationCaseInsensitive {
+			return compareFold(x, y), nil
+		}
+		return compareOrdered(x, y), nil
+	case int64, float64, Decimal:
+		// the int64 is the Unix seconds when it is compared with a time, e.g. (> signup_ts (date "2020-01-01"))
+		if y, ok := b.(time.Time); ok {
+			if x, ok := timeOf(a); ok {
+				return compareTimes(x, y), nil
+			}
+		}
+		return compareNumbers(m, a, b)
+	case time.Time:
+		y, ok := timeOf(b)
+		if !ok {
+			return 0, ParamTypeError(modeNames[m], typeTime, b)
+		}
+		return compareTimes(x, y), nil
+	case SemVer:
+		y, ok := b.(SemVer)
+		if !ok {
+			return 0, ParamTypeError(modeNames[m], typeSemVer, b)
+		}
+		return int64(x.Cmp(y)), nil
+	}
+
+	typ := reflect.TypeOf(a)
+	if cmp, ok := o.types[typ]; ok {
+		if reflect.TypeOf(b) != typ {
+			return 0, ParamTypeError(modeNames[m], typ.String(), b)
+		}
+		return int64(cmp(a, b)), nil
+	}
+	return 0, ParamTypeError(modeNames[m], typeOrdered, a)
+}
+
+func compareTimes(x, y time.Time) int64 {
+	switch {
+	case x.Before(y):
+		return -1
+	case x.After(y):
+		return 1
+	}
+	return 0
+}
+
+func compareNumbers(m mode, a, b Value) (int64, error) {
+	if isDecimal(a) || isDecimal(b) {
+		x, ok := toDecimal(a)
+		if !ok {
+			return 0, ParamTypeError(modeNames[m], typeDecimal, a)
+		}
+
+		y, ok := toDecimal(b)
+		if !ok {
+			return 0, ParamTypeError(modeNames[m], typeDecimal, b)
+		}
+		return int64(x.Cmp(y)), nil
+	}
+
+	i, ok1 := a.(int64)
+	j, ok2 := b.(int64)
+	if ok1 && ok2 {
+		return compareOrdered(i, j), nil
+	}
+
+	// compare as float64 when there are float params
+	x, ok := toFloat(a)
+	if !ok {
+		return 0, errTypeNumber(m, a)
+	}
+
+	y, ok := toFloat(b)
+	if !ok {
+		return 0, errTypeNumber(m, b)
+	}
+
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return 0, OpExecError(modeNames[m], errors.New("NaN can not be ordered"))
+	}
+	return compareOrdered(x, y), nil
+}
+
+// compareFold compares the strings under the Unicode lower case mapping, e.g. "Go" == "GO" < "golang"
+func compareFold(a, b string) int64 {
+	for a != "" && b != "" {
+		r1, s1 := utf8.DecodeRuneInString(a)
+		r2, s2 := utf8.DecodeRuneInString(b)
+		if r1 != r2 {
+			if c := compareOrdered(unicode.ToLower(r1), unicode.ToLower(r2)); c != 0 {
+				return c
+			}
+		}
+		a, b = a[s1:], b[s2:]
+	}
+	return compareOrdered(len(a), len(b))
+}
+
+func compareOrdered[T int | rune | int64 | float64 | string](a, b T) int64 {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
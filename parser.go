@@ -3,21 +3,29 @@ package eval
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type tokenType string
 
 const (
 	integer  tokenType = "integer"
+	float    tokenType = "float"
+	decimal  tokenType = "decimal"
+	duration tokenType = "duration"
 	str      tokenType = "str"
 	ident    tokenType = "ident"
 	lParen   tokenType = "lParen"
 	rParen   tokenType = "rParen"
 	lBracket tokenType = "lBracket"
 	rBracket tokenType = "rBracket"
+	lBrace   tokenType = "lBrace"
+	rBrace   tokenType = "rBrace"
+	colon    tokenType = "colon"
 	comment  tokenType = "comment"
 	comma    tokenType = "comma"
 )
@@ -43,10 +51,101 @@ const (
 	keywordFilter  keyword = "filter"
 	keywordReduce  keyword = "reduce"
 	keywordCollect keyword = "collect"
+	keywordCond    keyword = "cond"
+	keywordSwitch  keyword = "switch"
+
+	keywordExists   keyword = "exists"
+	keywordDefault  keyword = "default"
+	keywordCoalesce keyword = "coalesce"
+)
+
+var keywords = [...]keyword{keywordIf, keywordLet, keywordAny, keywordAll, keywordMap,
+	keywordFilter, keywordReduce, keywordCollect, keywordCond, keywordSwitch,
+	keywordExists, keywordDefault, keywordCoalesce}
+
+// specialForms are the keywords which probe their variable params,
+// a variable which can not be fetched is passed to the operators as the missing value instead of an error
+var specialForms = map[keyword]Operator{
+	keywordExists:   exists,
+	keywordDefault:  coalesce,
+	keywordCoalesce: coalesce,
+}
+
+// the heads of the last clauses of cond and switch
+const (
+	condElse      = "else"
+	switchDefault = "default"
 )
 
-var keywords = [...]keyword{keywordIf, keywordLet, keywordAny,
-	keywordAll, keywordMap, keywordFilter, keywordReduce, keywordCollect}
+// keywordInfo is the value of the nodes built by keywords which bind local variables
+type keywordInfo struct {
+	keyword  keyword
+	bindings []*binding
+}
+
+func (k *keywordInfo) String() string {
+	return string(k.keyword)
+}
+
+func (k *keywordInfo) isLoop() bool {
+	return k.keyword != keywordLet
+}
+
+// frameInputs returns the number of the loop frame elements pushed before the loop head,
+// they are the collection and the initial value of the reduce accumulator
+func (k *keywordInfo) frameInputs() int16 {
+	if k.keyword == keywordReduce {
+		return 2
+	}
+	return 1
+}
+
+// switchTable is the value of the jump table node of switch,
+// it selects the branch to execute by the value of the switch subject
+type switchTable struct {
+	labels  []Value       // the case values
+	cases   map[Value]int // the case value -> the branch number
+	targets []int16       // the node indexes to jump to before executing each branch, the last one is the default branch
+}
+
+func (t *switchTable) String() string {
+	return fmt.Sprint(t.labels)
+}
+
+// jump returns the node index to jump to for the switch subject,
+// the integral float and decimal subjects match the int cases, the same as eq
+func (t *switchTable) jump(v Value) int16 {
+	switch x := v.(type) {
+	case float64:
+		if x >= math.MinInt64 && x < math.MaxInt64 && x == math.Trunc(x) {
+			v = int64(x)
+		}
+	case Decimal:
+		if r := x.round(0, RoundDown, false, false); r.Cmp(x) == 0 {
+			v = r.coef
+		}
+	}
+
+	switch v.(type) {
+	case int64, string, bool:
+		if b, exist := t.cases[v]; exist {
+			return t.targets[b]
+		}
+	}
+	return t.targets[len(t.targets)-1]
+}
+
+// binding is a local variable bound by keywords (e.g. let).
+// The value of a local variable is kept in the operand stack during the evaluation,
+// the slot is the index of the value in the operand stack, it is calculated in the compile phase
+type binding struct {
+	name string
+	slot int16
+}
+
+func (b *binding) String() string {
+	return b.name
+}
 
 // ast
 type astNode struct {
@@ -55,6 +154,7 @@ type astNode struct {
 	cost      float64
 	idx       int
 	parentIdx int
+	pos       int // the token position of the leaf node in the source
 }
 
 type parser struct {
@@ -63,6 +163,11 @@ type parser struct {
 	tokens []token
 	idx    int
 
+	// local variables in the current scope
+	bindings []*binding
+	// probing allows the unknown variables, which are the params of the special forms, e.g. (exists promo_code)
+	probing bool
+
 	leafNodeParser []func() (*astNode, error)
 }
 
@@ -91,7 +196,10 @@ func (p *parser) lex() error {
 			start := i
 			i += 1
 			for ; i < len(A); i++ {
-				if A[i] == '"' {
+				switch A[i] {
+				case '\\':
+					i++ // skip the escaped character
+				case '"':
 					i++
 					return string(A[start:i]), nil
 				}
@@ -99,6 +207,19 @@ func (p *parser) lex() error {
 			return "", errors.New("unclosed quotes")
 		}
 
+		// lexRawString lexes the raw string in backticks, which has no escape sequences
+		lexRawString = func() (string, error) {
+			start := i
+			i += 1
+			for ; i < len(A); i++ {
+				if A[i] == '`' {
+					i++
+					return string(A[start:i]), nil
+				}
+			}
+			return "", errors.New("unclosed backticks")
+		}
+
 		nextToken = func() (string, error) {
 			start := i
 			for ; i < len(A); i++ {
@@ -109,6 +230,9 @@ func (p *parser) lex() error {
 				if i == start && r == '"' {
 					return lexString()
 				}
+				if i == start && r == '`' {
+					return lexRawString()
+				}
 				if unicode.IsSpace(r) {
 					if i == start {
 						start = i + 1
@@ -117,7 +241,7 @@ func (p *parser) lex() error {
 						break
 					}
 				}
-				if strings.ContainsRune("()[];,", r) {
+				if strings.ContainsRune("()[]{}:;,", r) {
 					break
 				}
 			}
@@ -134,7 +258,44 @@ func (p *parser) lex() error {
 		}
 
 		isValidInt = func(s string) bool {
-			_, err := strconv.ParseInt(s, 10, 64)
+			_, err := parseInt64(s)
+			return err == nil
+		}
+		isValidFloat = func(s string) bool {
+			// the float literal starts with a digit, e.g. 0.5, 1.5e3, -2.0, 1_000.5
+			d := strings.TrimLeft(s, "+-")
+			if d == "" || !unicode.IsDigit(rune(d[0])) || strings.ContainsAny(d, "xX") {
+				return false
+			}
+			_, err := strconv.ParseFloat(s, 64)
+			return err == nil
+		}
+		isOutOfRange = func(s string) bool {
+			if _, err := parseInt64(s); errors.Is(err, strconv.ErrRange) {
+				return true
+			}
+			if _, err := parseDuration(s); errors.Is(err, strconv.ErrRange) {
+				return true
+			}
+
+			d := strings.TrimLeft(s, "+-")
+			if d == "" || !unicode.IsDigit(rune(d[0])) || strings.ContainsAny(d, "xX") {
+				return false
+			}
+			_, err := strconv.ParseFloat(s, 64)
+			return errors.Is(err, strconv.ErrRange)
+		}
+		isValidDecimal = func(s string) bool {
+			// the decimal literal is a number with a fraction and the d suffix, e.g. 12.50d, -0.5d
+			if !strings.HasSuffix(s, "d") || !strings.Contains(s, ".") {
+				return false
+			}
+			_, err := ParseDecimal(s[:len(s)-1])
+			return err == nil
+		}
+		isValidDuration = func(s string) bool {
+			// the duration literal is a number with the unit suffixes, e.g. 7d, 36h, 15m, 1h30m
+			_, err := parseDuration(s)
 			return err == nil
 		}
 		isValidIdent = func(s string) bool {
@@ -195,7 +356,7 @@ func (p *parser) lex() error {
 			}
 		}
 
-		tk := token{val: t}
+		tk := token{val: t, pos: i - utf8.RuneCountInString(t)}
 		switch {
 		case t == "(":
 			tk.typ = lParen
@@ -205,15 +366,37 @@ func (p *parser) lex() error {
 			tk.typ = lBracket
 		case t == "]":
 			tk.typ = rBracket
+		case t == "{":
+			tk.typ = lBrace
+		case t == "}":
+			tk.typ = rBrace
+		case t == ":":
+			tk.typ = colon
 		case t == ",":
 			tk.typ = comma
 		case strings.HasPrefix(t, ";"):
 			tk.typ = comment
 		case strings.HasPrefix(t, `"`):
-			tk.val = t[1 : len(t)-1] // remove quotes
+			val, err := unquote(t[1 : len(t)-1]) // remove quotes
+			if err != nil {
+				return p.errWithPos(fmt.Errorf("invalid string literal %s, %w", t, err), tk.pos)
+			}
+			tk.val = val
+			tk.typ = str
+		case strings.HasPrefix(t, "`"):
+			tk.val = t[1 : len(t)-1] // remove backticks
 			tk.typ = str
 		case isValidInt(t):
 			tk.typ = integer
+		case isOutOfRange(t):
+			return p.errWithPos(fmt.Errorf("number literal out of range %s", t), tk.pos)
+		case isValidFloat(t):
+			tk.typ = float
+		case isValidDecimal(t):
+			tk.val = t[:len(t)-1] // remove the d suffix
+			tk.typ = decimal
+		case isValidDuration(t):
+			tk.typ = duration
 		case isValidIdent(t):
 			tk.typ = ident
 		default:
@@ -226,6 +409,74 @@ func (p *parser) lex() error {
 	return nil
 }
 
+// parseInt64 parses the integer literal, e.g. 42, -1_000, 0xFF, 0o17, 0b1010
+func parseInt64(s string) (int64, error) {
+	d := strings.TrimLeft(s, "+-")
+	if len(s)-len(d) > 1 {
+		return 0, &strconv.NumError{Func: "ParseInt", Num: s, Err: strconv.ErrSyntax}
+	}
+
+	// the base prefixes, the underscores are checked by strconv
+	if len(d) > 2 && d[0] == '0' && strings.ContainsRune("xXoObB", rune(d[1])) {
+		return strconv.ParseInt(s, 0, 64)
+	}
+
+	if strings.ContainsRune(d, '_') {
+		// the underscores must separate digits, e.g. 1_000_000
+		for _, part := range strings.Split(d, "_") {
+			if part == "" {
+				return 0, &strconv.NumError{Func: "ParseInt", Num: s, Err: strconv.ErrSyntax}
+			}
+		}
+		s = strings.ReplaceAll(s, "_", "")
+	}
+
+	// the scientific notation is an integer when its value is integral and fits in int64, e.g. 1e3, 15e17,
+	// otherwise it is a float, e.g. 1.5e3, 1e-3, 1e19
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		m, err := strconv.ParseInt(s[:i], 10, 64)
+		if err != nil {
+			return 0, &strconv.NumError{Func: "ParseInt", Num: s, Err: strconv.ErrSyntax}
+		}
+		exp, err := strconv.ParseUint(strings.TrimPrefix(s[i+1:], "+"), 10, 8)
+		if err != nil {
+			return 0, &strconv.NumError{Func: "ParseInt", Num: s, Err: strconv.ErrSyntax}
+		}
+		for ; exp > 0 && m != 0; exp-- {
+			if m > math.MaxInt64/10 || m < math.MinInt64/10 {
+				return 0, &strconv.NumError{Func: "ParseInt", Num: s, Err: strconv.ErrSyntax}
+			}
+			m *= 10
+		}
+		return m, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// unquote decodes the Go-style escape sequences in the string literal, e.g. \", \\, \n, \t, \uXXXX
+func unquote(s string) (string, error) {
+	if !strings.ContainsRune(s, '\\') {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for len(s) > 0 {
+		r, multibyte, tail, err := strconv.UnquoteChar(s, '"')
+		if err != nil {
+			return "", err
+		}
+
+		if r < utf8.RuneSelf || !multibyte {
+			sb.WriteByte(byte(r))
+		} else {
+			sb.WriteRune(r)
+		}
+		s = tail
+	}
+	return sb.String(), nil
+}
+
 func (p *parser) parseAstTree() (root *astNode, err error) {
 	n := 0
 	for _, t := range p.tokens {
@@ -260,7 +511,7 @@ func (p *parser) parseAstTree() (root *astNode, err error) {
 
 func (p *parser) setLeafNodeParsers() {
 	fns := []func() (*astNode, error){
-		p.parseInt, p.parseStr, p.parseConst, p.parseVariable, p.parseUnknownVariable}
+		p.parseInt, p.parseFloat, p.parseDecimal, p.parseDuration, p.parseStr, p.parseLocal, p.parseConst, p.parseVariable, p.parseField, p.parseUnknownVariable, p.parseMap}
 
 	if p.isInfixNotation() {
 		// For infix expressions only lists with brackets are supported
@@ -281,8 +532,7 @@ func (p *parser) check() error {
 		return p.parenUnmatchedErr(0)
 	}
 	// check parentheses
-	var parenCnt int
-	var inBracket bool
+	var parenCnt, bracketCnt, braceCnt int
 
 	for i, t := range p.tokens {
 		switch t.typ {
@@ -290,10 +540,25 @@ func (p *parser) check() error {
 			parenCnt++
 		case rParen:
 			parenCnt--
-		case comma, lBracket, rBracket:
-			if prefixNotation { // commas, brackets can be used in infix expressions only
+		case lBracket, rBracket:
+			if prefixNotation { // brackets can be used in infix expressions only
+				return p.unknownTokenError(t)
+			}
+		case comma:
+			if braceCnt > 0 { // the commas separate the entries of map literals
+				continue
+			}
+			if prefixNotation { // commas can be used in infix expressions only
 				return p.unknownTokenError(t)
 			}
+		case lBrace:
+			braceCnt++
+			continue
+		case rBrace:
+			if braceCnt--; braceCnt < 0 {
+				return p.parenUnmatchedErr(t.pos)
+			}
+			continue
 		default:
 			continue
 		}
@@ -306,18 +571,16 @@ func (p *parser) check() error {
 			return p.parenUnmatchedErr(t.pos)
 		}
 
-		if inBracket && t.typ != rBracket {
-			return p.invalidExprErr(t.pos)
-		}
-
 		if t.typ == lBracket {
-			inBracket = true
+			bracketCnt++
 		} else if t.typ == rBracket {
-			inBracket = false
+			if bracketCnt--; bracketCnt < 0 {
+				return p.parenUnmatchedErr(t.pos)
+			}
 		}
 	}
 
-	if parenCnt != 0 {
+	if parenCnt != 0 || braceCnt != 0 {
 		return p.parenUnmatchedErr(0)
 	}
 
@@ -341,7 +604,7 @@ func (p *parser) parse() (*astNode, *Config, error) {
 }
 
 func (p *parser) allowUndefinedVariable() bool {
-	return p.conf.CompileOptions[AllowUndefinedVariable]
+	return p.probing || p.conf.CompileOptions[AllowUndefinedVariable]
 }
 
 func (p *parser) isInfixNotation() bool {
@@ -391,11 +654,17 @@ func (p *parser) walk() {
 }
 
 func (p *parser) getOperator(opName string) (Operator, bool) {
-	op, exist := builtinOperators[opName]
-	if !exist {
-		op, exist = p.conf.OperatorMap[opName]
+	if !isBuiltinOperator(p.conf, opName) {
+		op, exist := p.conf.OperatorMap[opName]
+		return op, exist
+	}
+
+	// bind the builtin operator with the config, e.g. the decimal rounding and the string collation
+	op := builtinOperators[opName]
+	if bind, ok := configurableOperators[opName]; ok {
+		op = bind(p.conf)
 	}
-	return op, exist
+	return op, true
 }
 
 func (p *parser) invalidExprErr(pos int) error {
@@ -467,50 +736,168 @@ func (p *parser) valNode(v Value) *astNode {
 	}
 }
 
+// parseList parses the list literal, e.g. (1 2 3), ("a" true 1.5), (GOLD SILVER), ((1 2) (3 4)) or (1 (+ a 1)).
+// In the prefix notation, the parentheses are a list when the first element is a literal, a constant or a nested list,
+// and the elements can be any expressions. In the infix notation, the brackets are always a list of leaf nodes.
+// The list is a constant node when all the elements are constants, otherwise it is collected at runtime
 func (p *parser) parseList(leftType, rightType tokenType) func() (*astNode, error) {
 	return func() (*astNode, error) {
-		i := p.idx
-		T := p.tokens
-		if T[i].typ != leftType {
-			return nil, nil
+		t, err := p.peek()
+		if err != nil {
+			return nil, err
 		}
-		typ := T[i+1].typ
-		if typ != rightType && typ != integer && typ != str {
+		if t.typ != leftType || !p.isListStart(leftType, rightType) {
 			return nil, nil
 		}
-		strs := make([]string, 0)
-		for j := i + 1; j < len(T); j++ {
-			if T[j].typ == rightType {
-				i = j
+		p.walk()
+
+		var children []*astNode
+		for {
+			t, err = p.peek()
+			if err != nil {
+				return nil, err
+			}
+			if t.typ == rightType {
+				p.walk()
 				break
 			}
-			if T[j].typ != typ {
-				return nil, p.tokenTypeError(typ, T[j])
+
+			var child *astNode
+			if leftType == lParen {
+				child, err = p.parseExpression()
+			} else {
+				child, err = p.parseLeafElement()
+			}
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, child)
+		}
+
+		vals := make([]Value, 0, len(children))
+		for _, child := range children {
+			if child.node.getNodeType() != constant {
+				return p.buildKeywordNode(token{typ: ident, val: string(keywordCollect), pos: t.pos}, children)
 			}
-			strs = append(strs, T[j].val)
+			vals = append(vals, child.node.value)
 		}
+		return p.valNode(listLiteral(vals)), nil
+	}
+}
 
-		// todo: return error when list is empty?
+// isListStart checks if the parentheses (or brackets) at the current token are a list literal
+func (p *parser) isListStart(leftType, rightType tokenType) bool {
+	if leftType == lBracket || p.idx+1 >= len(p.tokens) {
+		return true
+	}
 
-		n := &node{flag: constant}
-		if typ == integer {
-			ints := make([]int64, 0, len(strs))
-			for _, s := range strs {
-				v, err := strconv.ParseInt(s, 10, 64)
-				if err != nil {
-					return nil, err
-				}
-				ints = append(ints, v)
+	switch t := p.tokens[p.idx+1]; t.typ {
+	case integer, float, decimal, duration, str, leftType, rightType, lBrace:
+		return true
+	case ident:
+		if _, exist := p.getOperator(t.val); exist || p.isKeyword(t) {
+			return false
+		}
+		for _, b := range p.bindings {
+			if b.name == t.val {
+				return false
 			}
-			n.value = ints
+		}
+		if _, exist := builtinConstants[t.val]; exist {
+			return true
+		}
+		_, exist := p.conf.ConstantMap[t.val]
+		return exist
+	}
+	return false
+}
+
+// parseMap parses the map literal, e.g. {"US": 10, "CA": 8}, the keys should be string literals.
+// The map is a constant node when all the values are constants, otherwise it is built by the dict operator
+func (p *parser) parseMap() (*astNode, error) {
+	t, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if t.typ != lBrace {
+		return nil, nil
+	}
+	p.walk()
+
+	var (
+		children []*astNode
+		keys     = make(map[string]struct{})
+	)
+	for {
+		t, err = p.next()
+		if err != nil {
+			return nil, err
+		}
+		if t.typ == rBrace && len(children) == 0 {
+			break
+		}
+		if t.typ != str {
+			return nil, p.tokenTypeError(str, t)
+		}
+		if _, exist := keys[t.val]; exist {
+			return nil, p.errWithToken(fmt.Errorf("duplicate map key %s", strconv.Quote(t.val)), t)
+		}
+		keys[t.val] = struct{}{}
+
+		if err = p.eat(colon); err != nil {
+			return nil, err
+		}
+
+		var val *astNode
+		if p.isInfixNotation() {
+			val, err = p.parseLeafElement()
 		} else {
-			n.value = strs
+			val, err = p.parseExpression()
 		}
-		p.idx = i + 1
-		return &astNode{
-			node: n,
-		}, nil
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, p.valNode(t.val), val)
+
+		t, err = p.next()
+		if err != nil {
+			return nil, err
+		}
+		if t.typ == rBrace {
+			break
+		}
+		if t.typ != comma {
+			return nil, p.tokenTypeError(comma, t)
+		}
+	}
+
+	vals := make([]Value, 0, len(children))
+	for _, child := range children {
+		if child.node.getNodeType() != constant {
+			return p.buildOperatorNode(token{typ: ident, val: "dict", pos: t.pos}, children)
+		}
+		vals = append(vals, child.node.value)
+	}
+
+	m, err := dict(nil, vals)
+	if err != nil {
+		return nil, err
+	}
+	return p.valNode(m), nil
+}
+
+// parseLeafElement parses the element of the list or map literals in the infix notation, only leaf nodes are allowed
+func (p *parser) parseLeafElement() (*astNode, error) {
+	ast, err := p.buildLeafNode()
+	if ast != nil || err != nil {
+		return ast, err
+	}
+
+	t, err := p.peek()
+	if err != nil {
+		return nil, err
 	}
+	return nil, p.invalidExprErr(t.pos)
 }
 
 func (p *parser) parseInt() (*astNode, error) {
@@ -521,13 +908,64 @@ func (p *parser) parseInt() (*astNode, error) {
 	if t.typ != integer {
 		return nil, nil
 	}
-	v, err := strconv.ParseInt(t.val, 10, 64)
+	v, err := parseInt64(t.val)
+	if err != nil {
+		return nil, err
+	}
+	p.walk()
+	return p.valNode(v), nil
+}
+func (p *parser) parseFloat() (*astNode, error) {
+	t, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if t.typ != float {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(t.val, 64)
+	if err != nil {
+		return nil, err
+	}
+	p.walk()
+	return p.valNode(v), nil
+}
+func (p *parser) parseDecimal() (*astNode, error) {
+	t, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if t.typ != decimal {
+		return nil, nil
+	}
+	v, err := ParseDecimal(t.val)
 	if err != nil {
 		return nil, err
 	}
 	p.walk()
 	return p.valNode(v), nil
 }
+
+// parseDuration parses the duration literal into seconds, e.g. 7d is 604800,
+// the node is marked to be dumped as the duration literal
+func (p *parser) parseDuration() (*astNode, error) {
+	t, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if t.typ != duration {
+		return nil, nil
+	}
+	v, err := parseDuration(t.val)
+	if err != nil {
+		return nil, err
+	}
+	p.walk()
+	ast := p.valNode(v)
+	ast.node.duration = true
+	return ast, nil
+}
+
 func (p *parser) parseStr() (*astNode, error) {
 	t, err := p.peek()
 	if err != nil {
@@ -560,6 +998,30 @@ func (p *parser) parseConst() (*astNode, error) {
 	return nil, nil
 }
 
+func (p *parser) parseLocal() (*astNode, error) {
+	t, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if t.typ != ident {
+		return nil, nil
+	}
+
+	// the inner scope local variables shadow the outer ones
+	for i := len(p.bindings) - 1; i >= 0; i-- {
+		if b := p.bindings[i]; b.name == t.val {
+			p.walk()
+			return &astNode{
+				node: &node{
+					flag:  local,
+					value: b,
+				},
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
 func (p *parser) parseVariable() (*astNode, error) {
 	t, err := p.peek()
 	if err != nil {
@@ -583,6 +1045,40 @@ func (p *parser) parseVariable() (*astNode, error) {
 	}, nil
 }
 
+// parseField parses the dotted variable in the nested field mode, e.g. address.country.
+// The root variable (address) should be registered, unless the undefined variables are allowed
+func (p *parser) parseField() (*astNode, error) {
+	if !p.conf.CompileOptions[NestedField] {
+		return nil, nil
+	}
+
+	t, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if t.typ != ident || !strings.Contains(t.val, ".") {
+		return nil, nil
+	}
+
+	key, ok := p.conf.VariableKeyMap[fieldRoot(t.val)]
+	if !ok {
+		if !p.allowUndefinedVariable() {
+			return nil, nil
+		}
+		key = UndefinedVarKey
+	}
+
+	p.walk()
+	return &astNode{
+		node: &node{
+			flag:     variable,
+			value:    t.val,
+			varKey:   key,
+			operator: newFieldPath(t.val, key).fetch,
+		},
+	}, nil
+}
+
 func (p *parser) parseUnknownVariable() (*astNode, error) {
 	if !p.allowUndefinedVariable() {
 		return nil, nil
@@ -616,8 +1112,19 @@ func (p *parser) parseUnknownVariable() (*astNode, error) {
 }
 
 func (p *parser) buildLeafNode() (ast *astNode, err error) {
+	var pos int
+	if t, err := p.peek(); err == nil {
+		pos = t.pos
+	}
+
 	for _, fn := range p.leafNodeParser {
 		ast, err = fn()
+		if ast != nil {
+			ast.pos = pos
+			if ast.node.getNodeType() == variable && p.conf.CompileOptions[UnixTime] {
+				ast.node.operator = unixTimeVariable(ast.node)
+			}
+		}
 		if ast != nil || err != nil {
 			return ast, err
 		}
@@ -652,6 +1159,17 @@ func (p *parser) parseExpression() (ast *astNode, err error) {
 		return nil, p.tokenTypeError(ident, car)
 	}
 
+	switch keyword(car.val) {
+	case keywordLet:
+		return p.parseLet(car)
+	case keywordAny, keywordAll, keywordMap, keywordFilter, keywordReduce:
+		return p.parseLoop(car)
+	case keywordCond:
+		return p.parseCond()
+	case keywordSwitch:
+		return p.parseSwitch()
+	}
+
 	var children []*astNode
 	for {
 		peek, err := p.peek()
@@ -663,7 +1181,11 @@ func (p *parser) parseExpression() (ast *astNode, err error) {
 			break
 		}
 
-		child, err := p.parseExpression()
+		parse := p.parseExpression
+		if _, ok := specialForms[keyword(car.val)]; ok {
+			parse = p.parseProbedParam
+		}
+		child, err := parse()
 		if err != nil {
 			return nil, err
 		}
@@ -678,15 +1200,369 @@ func (p *parser) parseExpression() (ast *astNode, err error) {
 	return p.buildParentNode(car, children)
 }
 
+// parseProbedParam parses the param of the special forms, the param can be an unknown variable,
+// e.g. promo_code of (exists promo_code) which is not in the variables, it is missing in the evaluation
+func (p *parser) parseProbedParam() (*astNode, error) {
+	ast, err := p.buildLeafNode()
+	if ast != nil || err != nil {
+		return ast, err
+	}
+
+	// only the variable itself is probed, the unknown variables of the subexpressions are still errors
+	p.probing = true
+	ast, err = p.buildLeafNode()
+	p.probing = false
+	if ast != nil || err != nil {
+		return ast, err
+	}
+	return p.parseExpression()
+}
+
+// parseLet parses the let expression, e.g. (let ((a 1) (b (+ a 1))) (* a b)).
+// The bindings are evaluated sequentially, so a binding can refer to the previous ones
+func (p *parser) parseLet(car token) (*astNode, error) {
+	err := p.eat(lParen)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &keywordInfo{keyword: keywordLet}
+	defer p.popBindings(len(p.bindings))
+
+	var children []*astNode
+	for {
+		peek, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+
+		if peek.typ == rParen {
+			break
+		}
+
+		err = p.eat(lParen)
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := p.parseBindingName()
+		if err != nil {
+			return nil, err
+		}
+
+		child, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		err = p.eat(rParen)
+		if err != nil {
+			return nil, err
+		}
+
+		info.bindings = append(info.bindings, b)
+		p.bindings = append(p.bindings, b)
+		children = append(children, child)
+	}
+
+	err = p.eat(rParen)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.eat(rParen)
+	if err != nil {
+		return nil, err
+	}
+
+	return &astNode{
+		node: &node{
+			flag:  scope,
+			value: info,
+		},
+		children: append(children, body),
+	}, nil
+}
+
+// parseLoop parses the loop expressions, e.g. (any x coll (> x 1)) or (reduce acc 0 x coll (+ acc x)).
+// The loop variable is bound to each element of the collection in the loop body,
+// the accumulator of reduce is bound to the result of the previous iteration
+func (p *parser) parseLoop(car token) (*astNode, error) {
+	info := &keywordInfo{keyword: keyword(car.val)}
+
+	var init *astNode
+	if info.keyword == keywordReduce {
+		acc, err := p.parseBindingName()
+		if err != nil {
+			return nil, err
+		}
+
+		init, err = p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		info.bindings = append(info.bindings, acc)
+	}
+
+	b, err := p.parseBindingName()
+	if err != nil {
+		return nil, err
+	}
+
+	coll, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	info.bindings = append(info.bindings, b)
+
+	p.bindings = append(p.bindings, info.bindings...)
+	body, err := p.parseExpression()
+	p.popBindings(len(p.bindings) - len(info.bindings))
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.eat(rParen)
+	if err != nil {
+		return nil, err
+	}
+
+	// the collection is pushed first, so the initial value
+	// of reduce is placed at the accumulator slot of the loop frame
+	inputs := []*astNode{coll}
+	if init != nil {
+		inputs = append(inputs, init)
+	}
+
+	return &astNode{
+		node: &node{
+			flag:  scope,
+			value: info,
+		},
+		children: append(inputs,
+			&astNode{node: &node{flag: loopHead, value: info}},
+			body,
+			&astNode{node: &node{flag: loopNext, value: info}},
+		),
+	}, nil
+}
+
+// parseCond parses the cond expression, e.g. (cond ((> a 10) 2) ((> a 5) 1) (else 0)).
+// The conditions are evaluated in order, the result of the first true clause is the result of cond
+func (p *parser) parseCond() (*astNode, error) {
+	var children []*astNode
+	for {
+		err := p.eatClause("cond requires an else clause")
+		if err != nil {
+			return nil, err
+		}
+
+		isElse, err := p.isLastClause(condElse)
+		if err != nil {
+			return nil, err
+		}
+
+		if isElse {
+			return p.parseLastClause(keywordCond, children)
+		}
+
+		c, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		err = p.eat(rParen)
+		if err != nil {
+			return nil, err
+		}
+
+		children = append(children, &astNode{
+			node: &node{
+				flag:  cond,
+				value: keywordCond,
+				// jump to the next clause when the condition returns false
+				operator: checkCondition,
+			},
+			children: []*astNode{c, v, newEndIfNode()},
+		})
+	}
+}
+
+// parseSwitch parses the switch expression, e.g. (switch tier ("gold" 30) ("silver" 20) (default 0)).
+// The case values should be constants, they are compiled into a jump table
+func (p *parser) parseSwitch() (*astNode, error) {
+	subject, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	table := &switchTable{cases: make(map[Value]int)}
+	var branches []*astNode
+	for {
+		err = p.eatClause("switch requires a default clause")
+		if err != nil {
+			return nil, err
+		}
+
+		isDefault, err := p.isLastClause(switchDefault)
+		if err != nil {
+			return nil, err
+		}
+
+		if isDefault {
+			table.targets = make([]int16, len(table.labels)+1)
+			return p.parseLastClause(keywordSwitch, []*astNode{
+				subject,
+				{
+					node:     &node{flag: jumpTable, value: table},
+					children: branches,
+				},
+			})
+		}
+
+		t, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+
+		label, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		if label.node.getNodeType() != constant {
+			return nil, p.errWithToken(fmt.Errorf("switch case [%s] is not a constant", t.val), t)
+		}
+
+		v := label.node.value
+		switch v.(type) {
+		case int64, string, bool:
+		default:
+			return nil, p.errWithToken(fmt.Errorf("switch case [%v] is not an int, string or bool", v), t)
+		}
+
+		if _, exist := table.cases[v]; exist {
+			return nil, p.errWithToken(fmt.Errorf("duplicate switch case [%v]", v), t)
+		}
+
+		res, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		err = p.eat(rParen)
+		if err != nil {
+			return nil, err
+		}
+
+		table.cases[v] = len(table.labels)
+		table.labels = append(table.labels, v)
+		branches = append(branches, res, newEndIfNode())
+	}
+}
+
+// eatClause eats the left paren of the next clause of cond or switch,
+// the clauses should end with the else clause of cond or the default clause of switch
+func (p *parser) eatClause(missingLast string) error {
+	t, err := p.peek()
+	if err != nil {
+		return err
+	}
+
+	if t.typ == rParen {
+		return p.errWithToken(errors.New(missingLast), t)
+	}
+	return p.eat(lParen)
+}
+
+// isLastClause checks if the next clause is the else clause of cond or the default clause of switch
+func (p *parser) isLastClause(head string) (bool, error) {
+	t, err := p.peek()
+	if err != nil {
+		return false, err
+	}
+
+	if t.typ != ident || t.val != head {
+		return false, nil
+	}
+
+	_, err = p.next()
+	return err == nil, err
+}
+
+// parseLastClause parses the last clause of cond or switch, and builds the cond or switch node.
+// The result of the last clause is appended to the last child
+func (p *parser) parseLastClause(kw keyword, children []*astNode) (*astNode, error) {
+	v, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < 2; i++ {
+		err = p.eat(rParen)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if kw == keywordSwitch {
+		table := children[len(children)-1]
+		table.children = append(table.children, v)
+	} else {
+		children = append(children, v)
+	}
+
+	return &astNode{
+		node: &node{
+			flag:  multiCond,
+			value: kw,
+		},
+		children: children,
+	}, nil
+}
+
+func (p *parser) parseBindingName() (*binding, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if t.typ != ident {
+		return nil, p.tokenTypeError(ident, t)
+	}
+
+	if _, exist := builtinConstants[t.val]; exist || p.isKeyword(t) {
+		return nil, p.errWithToken(fmt.Errorf("[%s] can not be used as a local variable name", t.val), t)
+	}
+	return &binding{name: t.val}, nil
+}
+
+func (p *parser) popBindings(size int) {
+	p.bindings = p.bindings[:size]
+}
+
 func (p *parser) parseInfixExpression() (*astNode, error) {
 	type op struct {
-		t token // token
-		l int   // output stack size
+		t    token // token
+		l    int   // output stack size
+		call bool  // the function call, e.g. f in f(x) and band in band(x, y)
 	}
 
 	var (
 		operatorStack []op
 		outputStack   []*astNode
+		// operand is true when the last parsed tokens are an operand, e.g. a, f(x) or a[0],
+		// the brackets following an operand are an index expression, other brackets are a list literal
+		operand bool
 	)
 
 	var (
@@ -707,6 +1583,33 @@ func (p *parser) parseInfixExpression() (*astNode, error) {
 			return p1 - p2
 		}
 
+		buildTopOperator = func() error {
+			l := len(operatorStack)
+			top := operatorStack[l-1]
+			operatorStack = operatorStack[:l-1]
+
+			cnt := p.getInfixOpInfo(top.t.val).childCount
+			if cnt == -1 {
+				cnt = len(outputStack) - top.l
+			}
+			if cnt > len(outputStack) {
+				return p.invalidExprErr(top.t.pos)
+			}
+
+			children := make([]*astNode, cnt)
+			for i := cnt - 1; i >= 0; i-- {
+				children[i] = pop()
+			}
+
+			ast, err := p.buildParentNode(top.t, children)
+			if err != nil {
+				return err
+			}
+
+			push(ast)
+			return nil
+		}
+
 		buildTopOperators = func(car token) error {
 			for l := len(operatorStack); l != 0; l = len(operatorStack) {
 				top := operatorStack[l-1]
@@ -719,36 +1622,55 @@ func (p *parser) parseInfixExpression() (*astNode, error) {
 					break
 				}
 
-				operatorStack = operatorStack[:l-1]
-
-				cnt := p.getInfixOpInfo(top.t.val).childCount
-				if cnt == -1 {
-					cnt = len(outputStack) - top.l
-				}
-
-				children := make([]*astNode, cnt)
-				for i := cnt - 1; i >= 0; i-- {
-					children[i] = pop()
-				}
-
-				ast, err := p.buildParentNode(top.t, children)
-				if err != nil {
+				if err := buildTopOperator(); err != nil {
 					return err
 				}
-
-				push(ast)
 			}
 			return nil
 		}
 	)
 
 	for p.hasNext() {
+		t, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+
+		// the index expressions bind tighter than all the operators, e.g. a[0], f(x)[0] and m["k"][i + 1]
+		if operand && t.typ == lBracket {
+			// the function call is the operand when its arguments are closed
+			if l := len(operatorStack); l != 0 && operatorStack[l-1].call {
+				if err = buildTopOperator(); err != nil {
+					return nil, err
+				}
+			}
+			ast, err := p.parseIndex(pop())
+			if err != nil {
+				return nil, err
+			}
+			push(ast)
+			continue
+		}
+
+		// the end of the index expression
+		if t.typ == rBracket {
+			break
+		}
+
+		// the params of the special forms can be unknown variables, e.g. exists(promo_code)
+		if l := len(operatorStack); l >= 2 && operatorStack[l-1].t.typ == lParen {
+			_, special := specialForms[keyword(operatorStack[l-2].t.val)]
+			p.probing = special && p.idx+1 < len(p.tokens) &&
+				(p.tokens[p.idx+1].typ == comma || p.tokens[p.idx+1].typ == rParen)
+		}
 		ast, err := p.buildLeafNode()
+		p.probing = false
 		if err != nil {
 			return nil, err
 		}
 		if ast != nil {
 			push(ast)
+			operand = true
 			continue
 		}
 
@@ -758,19 +1680,29 @@ func (p *parser) parseInfixExpression() (*astNode, error) {
 		}
 		switch car.typ {
 		case ident:
-			err = buildTopOperators(car)
-			if err != nil {
-				return nil, err
+			// the binary operators can be called as functions, unless they follow an operand, e.g. x band (y),
+			// the function calls start an operand, so the operators on the stack are not built
+			info := p.getInfixOpInfo(car.val)
+			call := info.precedence == funcPrecedence ||
+				info.childCount == 2 && !operand && p.hasNext() && p.tokens[p.idx].typ == lParen
+			if !call {
+				if err = buildTopOperators(car); err != nil {
+					return nil, err
+				}
 			}
-			operatorStack = append(operatorStack, op{t: car, l: len(outputStack)})
+			operatorStack = append(operatorStack, op{t: car, l: len(outputStack), call: call})
+			operand = false
 		case lParen:
 			operatorStack = append(operatorStack, op{t: car, l: len(outputStack)})
+			operand = false
 		case rParen:
 			err = buildTopOperators(car)
 			if err != nil {
 				return nil, err
 			}
+			operand = true
 		case comma:
+			operand = false
 			err = buildTopOperators(car)
 			if err != nil {
 				return nil, err
@@ -792,6 +1724,27 @@ func (p *parser) parseInfixExpression() (*astNode, error) {
 	return pop(), nil
 }
 
+// parseIndex parses the index expression following the operand to the get operator call,
+// e.g. a[0] to get(a, 0), and m["k"][i + 1] to get(get(m, "k"), i + 1)
+func (p *parser) parseIndex(operand *astNode) (*astNode, error) {
+	t, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.eat(lBracket); err != nil {
+		return nil, err
+	}
+
+	index, err := p.parseInfixExpression()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.eat(rBracket); err != nil {
+		return nil, err
+	}
+	return p.buildParentNode(token{typ: ident, val: modeNames[getElem], pos: t.pos}, []*astNode{operand, index})
+}
+
 type infixOpInfo struct {
 	precedence int
 	childCount int
@@ -801,13 +1754,15 @@ const funcPrecedence = 100
 
 func (p *parser) getInfixOpInfo(op string) infixOpInfo {
 	switch op {
-	case "*", "/", "%":
+	case "bnot":
+		return infixOpInfo{precedence: 9, childCount: 1}
+	case "*", "/", "%", "band", "shl", "shr", "<<", ">>":
 		return infixOpInfo{precedence: 8, childCount: 2}
-	case "+", "-":
+	case "+", "-", "bor", "bxor":
 		return infixOpInfo{precedence: 7, childCount: 2}
 	case "!":
 		return infixOpInfo{precedence: 6, childCount: 1}
-	case "=", "==", "!=", "<", ">", "<=", ">=":
+	case "=", "==", "!=", "<", ">", "<=", ">=", "has_bits", "in":
 		return infixOpInfo{precedence: 5, childCount: 2}
 	case "&", "&&":
 		return infixOpInfo{precedence: 4, childCount: 2}
@@ -846,6 +1801,21 @@ func (p *parser) buildParentNode(car token, children []*astNode) (*astNode, erro
 }
 
 func (p *parser) buildKeywordNode(car token, children []*astNode) (*astNode, error) {
+	if car.val == string(keywordCollect) {
+		return &astNode{
+			children: children,
+			node: &node{
+				flag:     operator,
+				value:    car.val,
+				operator: collect,
+			},
+		}, nil
+	}
+
+	if op, ok := specialForms[keyword(car.val)]; ok {
+		return p.buildSpecialFormNode(car, op, children)
+	}
+
 	if car.val != string(keywordIf) {
 		return nil, p.errWithToken(fmt.Errorf("[%s] is not currently supported", car.val), car)
 	}
@@ -859,34 +1829,101 @@ func (p *parser) buildKeywordNode(car token, children []*astNode) (*astNode, err
 			flag:  cond,
 			value: keywordIf,
 			// trigger short circuit when cond node returns false
-			operator: func(_ *Ctx, params []Value) (Value, error) {
-				if b, ok := params[0].(bool); ok {
-					return !b, nil
-				}
-
-				return nil, fmt.Errorf("condition node returns a non bool result: [%v]", params[0])
-			},
+			operator: checkCondition,
 		},
 
 		// append an end if node
-		children: append(children, &astNode{
-			node: &node{
-				flag:  cond,
-				value: "fi",
-				operator: func(_ *Ctx, _ []Value) (Value, error) {
-					return true, nil
-				},
-			},
-		}),
+		children: append(children, newEndIfNode()),
+	}, nil
+}
+
+// buildSpecialFormNode builds the exists, default and coalesce expressions,
+// e.g. (exists promo_code), (default promo_code "") and (coalesce nickname name "guest").
+// Their variable params are compiled to probe nodes, so the missing variables don't fail the evaluation
+func (p *parser) buildSpecialFormNode(car token, op Operator, children []*astNode) (*astNode, error) {
+	switch keyword(car.val) {
+	case keywordExists:
+		if len(children) != 1 {
+			return nil, p.paramsCountErr(1, len(children), car)
+		}
+		if children[0].node.getNodeType() != variable {
+			return nil, p.errWithToken(fmt.Errorf("[%s] only accepts a variable", car.val), car)
+		}
+	case keywordDefault:
+		if len(children) != 2 {
+			return nil, p.paramsCountErr(2, len(children), car)
+		}
+	case keywordCoalesce:
+		if len(children) < 2 {
+			return nil, p.paramsCountErr(2, len(children), car)
+		}
+	}
+
+	for _, child := range children {
+		if child.node.getNodeType() == variable {
+			child.node.flag = probe
+		}
+	}
+
+	return &astNode{
+		children: children,
+		node: &node{
+			flag:     operator,
+			value:    car.val,
+			operator: op,
+		},
 	}, nil
 }
 
+// isSpecialFormNode checks if the node is built by the special forms, which handle DNE params by themselves
+func isSpecialFormNode(n *node) bool {
+	s, ok := n.value.(string)
+	if !ok {
+		return false
+	}
+	_, ok = specialForms[keyword(s)]
+	return ok
+}
+
+func checkCondition(_ *Ctx, params []Value) (Value, error) {
+	if b, ok := params[0].(bool); ok {
+		return !b, nil
+	}
+
+	return nil, fmt.Errorf("condition node returns a non bool result: [%v]", params[0])
+}
+
+// newEndIfNode builds the node which jumps to the end of the conditional expression
+func newEndIfNode() *astNode {
+	return &astNode{
+		node: &node{
+			flag:  cond,
+			value: "fi",
+			operator: func(_ *Ctx, _ []Value) (Value, error) {
+				return true, nil
+			},
+		},
+	}
+}
+
 func (p *parser) buildOperatorNode(car token, children []*astNode) (*astNode, error) {
 	// parse op node
 	op, exist := p.getOperator(car.val)
 	if !exist {
 		return nil, p.unknownTokenError(car)
 	}
+
+	// precompile the constant param, e.g. the regex pattern of (matches email ".*@corp\.com$")
+	if precompile, ok := precompiledOperators[car.val]; ok && isBuiltinOperator(p.conf, car.val) && len(children) >= 2 {
+		str, isStr := children[1].node.value.(string)
+		if isStr && children[1].node.getNodeType() == constant {
+			var err error
+			if op, err = precompile(str); err != nil {
+				return nil, p.errWithPos(err, children[1].pos)
+			}
+		}
+	}
+
 	return &astNode{
 		children: children,
 		node: &node{
@@ -116,6 +116,49 @@ func TestLex(t *testing.T) {
 			},
 			cc: NewConfig(EnableInfixNotation),
 		},
+		{
+			expr: `(< (since created) 7d1h -90s 1.5d)`,
+			tokens: []token{
+				{typ: lParen, val: "("},
+				{typ: ident, val: "<"},
+				{typ: lParen, val: "("},
+				{typ: ident, val: "since"},
+				{typ: ident, val: "created"},
+				{typ: rParen, val: ")"},
+				{typ: duration, val: "7d1h"},
+				{typ: duration, val: "-90s"},
+				{typ: decimal, val: "1.5"},
+				{typ: rParen, val: ")"},
+			},
+		},
+		{
+			expr:   `(< (since created) 15m1h)`,
+			errMsg: "can not parse token",
+		},
+		{
+			expr:   `(< (since created) 1.5h)`, // the durations are integers
+			errMsg: "can not parse token",
+		},
+		{
+			expr:   `(< (since created) 999999999999999999d)`,
+			errMsg: "number literal out of range",
+		},
+		{
+			expr: `{"US": 10, "CA": [8]}`,
+			tokens: []token{
+				{typ: lBrace, val: "{"},
+				{typ: str, val: "US"},
+				{typ: colon, val: ":"},
+				{typ: integer, val: "10"},
+				{typ: comma, val: ","},
+				{typ: str, val: "CA"},
+				{typ: colon, val: ":"},
+				{typ: lBracket, val: "["},
+				{typ: integer, val: "8"},
+				{typ: rBracket, val: "]"},
+				{typ: rBrace, val: "}"},
+			},
+		},
 
 		{
 			expr: `1 + 2 == -3`,
@@ -398,11 +441,145 @@ func TestLex(t *testing.T) {
 		},
 
 		{
-			expr:   `(< age 18.0)`, // can not parse float
+			expr: `(< age 18.0)`,
+			tokens: []token{
+				{typ: lParen, val: "("},
+				{typ: ident, val: "<"},
+				{typ: ident, val: "age"},
+				{typ: float, val: "18.0"},
+				{typ: rParen, val: ")"},
+			},
+		},
+		{
+			expr: `(+ -1 1.5e3 (0.5 2))`,
+			tokens: []token{
+				{typ: lParen, val: "("},
+				{typ: ident, val: "+"},
+				{typ: integer, val: "-1"},
+				{typ: float, val: "1.5e3"},
+				{typ: lParen, val: "("},
+				{typ: float, val: "0.5"},
+				{typ: integer, val: "2"},
+				{typ: rParen, val: ")"},
+				{typ: rParen, val: ")"},
+			},
+		},
+		{
+			expr: `(= s "say \"hi\"\n\t\\ \u00e9 \x41")`,
+			tokens: []token{
+				{typ: lParen, val: "("},
+				{typ: ident, val: "="},
+				{typ: ident, val: "s"},
+				{typ: str, val: "say \"hi\"\n\t\\ é A"},
+				{typ: rParen, val: ")"},
+			},
+		},
+		{
+			expr: "(matches s `^\\d+ \"(x)\"$`)",
+			tokens: []token{
+				{typ: lParen, val: "("},
+				{typ: ident, val: "matches"},
+				{typ: ident, val: "s"},
+				{typ: str, val: `^\d+ "(x)"$`},
+				{typ: rParen, val: ")"},
+			},
+		},
+		{
+			expr: "(concat `line 1\nline 2` \"\")",
+			tokens: []token{
+				{typ: lParen, val: "("},
+				{typ: ident, val: "concat"},
+				{typ: str, val: "line 1\nline 2"},
+				{typ: str, val: ""},
+				{typ: rParen, val: ")"},
+			},
+		},
+		{
+			expr:   `(matches s "\d+")`,
+			errMsg: `invalid string literal "\d+", invalid syntax occurs at  (matches s ["]\d+")`,
+		},
+		{
+			expr:   `(= s "abc\")`,
+			errMsg: "unclosed quotes",
+		},
+		{
+			expr:   "(= s `abc)",
+			errMsg: "unclosed backticks",
+		},
+		{
+			expr: `(< 12.50d -0.5d 7)`,
+			tokens: []token{
+				{typ: lParen, val: "("},
+				{typ: ident, val: "<"},
+				{typ: decimal, val: "12.50"},
+				{typ: decimal, val: "-0.5"},
+				{typ: integer, val: "7"},
+				{typ: rParen, val: ")"},
+			},
+		},
+		{
+			expr: `(+ 1 7d)`, // decimal literals have a fraction, 7d is a duration
+			tokens: []token{
+				{typ: lParen, val: "("},
+				{typ: ident, val: "+"},
+				{typ: integer, val: "1"},
+				{typ: duration, val: "7d"},
+				{typ: rParen, val: ")"},
+			},
+		},
+		{
+			expr: `(+ 0xFF -0o17 0b1010 1_000_000 1_000.5 1e9)`,
+			tokens: []token{
+				{typ: lParen, val: "("},
+				{typ: ident, val: "+"},
+				{typ: integer, val: "0xFF"},
+				{typ: integer, val: "-0o17"},
+				{typ: integer, val: "0b1010"},
+				{typ: integer, val: "1_000_000"},
+				{typ: float, val: "1_000.5"},
+				{typ: integer, val: "1e9"},
+				{typ: rParen, val: ")"},
+			},
+		},
+		{
+			expr: `(+ 15E17 1e+3 1e-3 2.5e3 1e19)`, // integral scientific notations in the int64 range are integers
+			tokens: []token{
+				{typ: lParen, val: "("},
+				{typ: ident, val: "+"},
+				{typ: integer, val: "15E17"},
+				{typ: integer, val: "1e+3"},
+				{typ: float, val: "1e-3"},
+				{typ: float, val: "2.5e3"},
+				{typ: float, val: "1e19"},
+				{typ: rParen, val: ")"},
+			},
+		},
+		{
+			expr:   `(= v 99999999999999999999)`,
+			errMsg: "number literal out of range 99999999999999999999 occurs at  (= v [9]9999999999999999999)",
+		},
+		{
+			expr:   `(= v 0x1_0000_0000_0000_0000)`,
+			errMsg: "number literal out of range",
+		},
+		{
+			expr:   `(> v 1e400)`,
+			errMsg: "number literal out of range 1e400 occurs at  (> v [1]e400)",
+		},
+		{
+			expr:   `(+ 1 1__000)`, // underscores must separate digits
 			errMsg: "can not parse token",
 		},
 		{
-			expr:   `(+ 1 1.0)`, // can not parse float
+			expr:   `(+ 1 0x)`,
+			errMsg: "can not parse token",
+		},
+		{
+			expr:   `(+ 1 .5)`, // float literals start with a digit
+			errMsg: "can not parse token",
+		},
+		{
+			expr:   `(+ 1 1.0.0)`,
 			errMsg: "can not parse token",
 		},
 		{
@@ -1098,27 +1275,297 @@ func TestParseAstTree(t *testing.T) {
 			errMsg: "unknown token error",
 		},
 
+		{
+			cc: NewConfig(RegVarAndOp(map[string]interface{}{
+				"a": 1,
+			})),
+			expr: `(let ((a 1) (b (+ a 1))) (* a b))`,
+			ast: verifyNode{
+				tpy: scope,
+				data: &keywordInfo{
+					keyword:  keywordLet,
+					bindings: []*binding{{name: "a"}, {name: "b"}},
+				},
+				children: []verifyNode{
+					{tpy: constant, data: int64(1)},
+					{
+						tpy:  operator,
+						data: "+",
+						children: []verifyNode{
+							{tpy: local, data: &binding{name: "a"}},
+							{tpy: constant, data: int64(1)},
+						},
+					},
+					{
+						tpy:  operator,
+						data: "*",
+						children: []verifyNode{
+							{tpy: local, data: &binding{name: "a"}},
+							{tpy: local, data: &binding{name: "b"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			cc: NewConfig(RegVarAndOp(map[string]interface{}{
+				"tags": []string{},
+			})),
+			expr: `(any t tags (= t "vip"))`,
+			ast: verifyNode{
+				tpy: scope,
+				data: &keywordInfo{
+					keyword:  keywordAny,
+					bindings: []*binding{{name: "t"}},
+				},
+				children: []verifyNode{
+					{tpy: variable, data: "tags"},
+					{
+						tpy: loopHead,
+						data: &keywordInfo{
+							keyword:  keywordAny,
+							bindings: []*binding{{name: "t"}},
+						},
+					},
+					{
+						tpy:  operator,
+						data: "=",
+						children: []verifyNode{
+							{tpy: local, data: &binding{name: "t"}},
+							{tpy: constant, data: "vip"},
+						},
+					},
+					{
+						tpy: loopNext,
+						data: &keywordInfo{
+							keyword:  keywordAny,
+							bindings: []*binding{{name: "t"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			expr:   `(let ((a 1)) (+ a b))`,
+			errMsg: "unknown token error",
+		},
+		{
+			expr:   `(all x (x) (> x 1))`,
+			errMsg: "unknown token error",
+		},
+		{
+			expr:   `(any x (1 2))`,
+			errMsg: "token type unexpected error",
+		},
+		{
+			expr:   `(any (1 2) (> 1 0))`,
+			errMsg: "token type unexpected error",
+		},
+		{
+			expr:   `(reduce acc x (1 2) (+ acc x))`,
+			errMsg: "unknown token error",
+		},
+		{
+			expr:   `(reduce acc 0 x (1 2))`,
+			errMsg: "token type unexpected error",
+		},
+		{
+			expr:   `(cond ((> 1 0) 1))`,
+			errMsg: "cond requires an else clause",
+		},
+		{
+			expr:   `(switch 1 (1 2))`,
+			errMsg: "switch requires a default clause",
+		},
+		{
+			expr:   `(cond ((> 1 0) 1) (else 2) (else 3))`,
+			errMsg: "token type unexpected error",
+		},
+		{
+			expr:   `(switch 1 (1 2) (1 3) (default 4))`,
+			errMsg: "duplicate switch case [1]",
+		},
+		{
+			expr:   `(switch 1 ((+ 1 1) 2) (default 4))`,
+			errMsg: "switch case [(] is not a constant",
+		},
+		{
+			expr:   `(switch 1 ((1 2) 2) (default 4))`,
+			errMsg: "switch case [[1 2]] is not an int, string or bool",
+		},
+		{
+			expr:   `(map if (1 2) if)`,
+			errMsg: "[if] can not be used as a local variable name",
+		},
+		{
+			expr:   `(filter x (1 2) (> x 0) x)`,
+			errMsg: "token type unexpected error",
+		},
+		{
+			expr:   `(let ((true 1)) true)`,
+			errMsg: "[true] can not be used as a local variable name",
+		},
+		{
+			expr:   `(let (("a" 1)) 1)`,
+			errMsg: "token type unexpected error",
+		},
+		{
+			expr:   `(let ((a 1)))`,
+			errMsg: "token type unexpected error",
+		},
+
 		// return an error when expr use unregister operator
 		{
 			expr:   `(is_child 18)`,
 			errMsg: "unknown token error",
 		},
-		// mismatched element types in the list
 		{
 			expr:   `(17 age 18)`,
+			errMsg: "unknown token error",
+		},
+		{
+			expr: `(17 18 "19")`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: []Value{int64(17), int64(18), "19"},
+			},
+		},
+		{
+			expr: `(())`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: []Value{[]string{}},
+			},
+		},
+		{
+			expr: `(1 2.5 -3)`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: []float64{1, 2.5, -3},
+			},
+		},
+		{
+			expr: `(true false null)`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: []Value{true, false, nil},
+			},
+		},
+		{
+			cc: &Config{
+				VariableKeyMap: map[string]VariableKey{
+					"tier": VariableKey(1),
+				},
+				ConstantMap: map[string]Value{
+					"GOLD":   "gold",
+					"SILVER": "silver",
+				},
+			},
+			expr: `(in tier (GOLD SILVER))`,
+			ast: verifyNode{
+				tpy:  operator,
+				data: "in",
+				children: []verifyNode{
+					{tpy: variable, data: "tier"},
+					{tpy: constant, data: []string{"gold", "silver"}},
+				},
+			},
+		},
+		{
+			expr: `((1 2) ("a") 3.0d)`,
+			ast: verifyNode{
+				tpy:  constant,
+				data: []Value{[]int64{1, 2}, []string{"a"}, mustDecimal(30, 1)},
+			},
+		},
+		{
+			cc: NewConfig(RegVarAndOp(map[string]interface{}{
+				"a": 1,
+			})),
+			expr: `(1 (+ a 1))`,
+			ast: verifyNode{
+				tpy:  operator,
+				data: "collect",
+				children: []verifyNode{
+					{tpy: constant, data: int64(1)},
+					{
+						tpy:  operator,
+						data: "+",
+						children: []verifyNode{
+							{tpy: variable, data: "a"},
+							{tpy: constant, data: int64(1)},
+						},
+					},
+				},
+			},
+		},
+		{
+			expr: `(get {"US": 10, "CA": 8} "US")`,
+			ast: verifyNode{
+				tpy:  operator,
+				data: "get",
+				children: []verifyNode{
+					{tpy: constant, data: map[string]int64{"US": 10, "CA": 8}},
+					{tpy: constant, data: "US"},
+				},
+			},
+		},
+		{
+			expr: `(get {"a": (1 2), "b": {}, "c": "x"} "a")`,
+			ast: verifyNode{
+				tpy:  operator,
+				data: "get",
+				children: []verifyNode{
+					{tpy: constant, data: map[string]Value{"a": []int64{1, 2}, "b": map[string]Value{}, "c": "x"}},
+					{tpy: constant, data: "a"},
+				},
+			},
+		},
+		{
+			cc: NewConfig(RegVarAndOp(map[string]interface{}{
+				"a": 1,
+			})),
+			expr: `(get {"x": a} "x")`,
+			ast: verifyNode{
+				tpy:  operator,
+				data: "get",
+				children: []verifyNode{
+					{
+						tpy:  operator,
+						data: "dict",
+						children: []verifyNode{
+							{tpy: constant, data: "x"},
+							{tpy: variable, data: "a"},
+						},
+					},
+					{tpy: constant, data: "x"},
+				},
+			},
+		},
+		{
+			expr:   `(get {"a": 1, "a": 2} "a")`,
+			errMsg: `duplicate map key "a"`,
+		},
+		{
+			expr:   `(get {a: 1} "a")`,
 			errMsg: "token type unexpected error",
 		},
-
-		// mismatched element types in the list
 		{
-			expr:   `(17 18 "19")`,
+			expr:   `(get {"a" 1} "a")`,
 			errMsg: "token type unexpected error",
 		},
-
 		{
-			expr:   `(())`,
+			expr:   `(get {"a": 1, } "a")`,
 			errMsg: "token type unexpected error",
 		},
+		{
+			expr:   `(get {"a": 1 "a")`,
+			errMsg: "parentheses unmatched error",
+		},
+		{
+			expr:   `(in 1 (1, 2))`,
+			errMsg: "unknown token error",
+		},
 
 		{
 			expr:   `(if (= 1 1))`,
@@ -1961,6 +2408,165 @@ func TestParseInfixAstTree(t *testing.T) {
 				},
 			},
 		},
+		{
+			expr: `in(a, [[1 2] ["x" true] 1.5])`,
+			ast: verifyNode{
+				tpy:  operator,
+				data: "in",
+				children: []verifyNode{
+					{tpy: variable, data: "a"},
+					{tpy: constant, data: []Value{[]int64{1, 2}, []Value{"x", true}, 1.5}},
+				},
+			},
+			cc: &Config{
+				CompileOptions: map[CompileOption]bool{
+					InfixNotation:          true,
+					AllowUndefinedVariable: true,
+				},
+			},
+		},
+		{
+			expr: `{"US": 10, "CA": a}["US"] > 8`,
+			ast: verifyNode{
+				tpy:  operator,
+				data: ">",
+				children: []verifyNode{
+					{
+						tpy:  operator,
+						data: "get",
+						children: []verifyNode{
+							{
+								tpy:  operator,
+								data: "dict",
+								children: []verifyNode{
+									{tpy: constant, data: "US"},
+									{tpy: constant, data: int64(10)},
+									{tpy: constant, data: "CA"},
+									{tpy: variable, data: "a"},
+								},
+							},
+							{tpy: constant, data: "US"},
+						},
+					},
+					{tpy: constant, data: int64(8)},
+				},
+			},
+			cc: &Config{
+				CompileOptions: map[CompileOption]bool{
+					InfixNotation:          true,
+					AllowUndefinedVariable: true,
+				},
+			},
+		},
+		{
+			expr: `xs[0] in [1 2]`,
+			ast: verifyNode{
+				tpy:  operator,
+				data: "in",
+				children: []verifyNode{
+					{
+						tpy:  operator,
+						data: "get",
+						children: []verifyNode{
+							{tpy: variable, data: "xs"},
+							{tpy: constant, data: int64(0)},
+						},
+					},
+					{tpy: constant, data: []int64{1, 2}},
+				},
+			},
+			cc: &Config{
+				CompileOptions: map[CompileOption]bool{
+					InfixNotation:          true,
+					AllowUndefinedVariable: true,
+				},
+			},
+		},
+		{
+			expr: `!in(f(a)[i + 1], [[1 2] [3]][1])`,
+			ast: verifyNode{
+				tpy:  operator,
+				data: "!",
+				children: []verifyNode{
+					{
+						tpy:  operator,
+						data: "in",
+						children: []verifyNode{
+							{
+								tpy:  operator,
+								data: "get",
+								children: []verifyNode{
+									{
+										tpy:  operator,
+										data: "f",
+										children: []verifyNode{
+											{tpy: variable, data: "a"},
+										},
+									},
+									{
+										tpy:  operator,
+										data: "+",
+										children: []verifyNode{
+											{tpy: variable, data: "i"},
+											{tpy: constant, data: int64(1)},
+										},
+									},
+								},
+							},
+							{
+								tpy:  operator,
+								data: "get",
+								children: []verifyNode{
+									{tpy: constant, data: []Value{[]int64{1, 2}, []int64{3}}},
+									{tpy: constant, data: int64(1)},
+								},
+							},
+						},
+					},
+				},
+			},
+			cc: &Config{
+				OperatorMap: map[string]Operator{
+					"f": func(_ *Ctx, params []Value) (Value, error) {
+						return params[0], nil
+					},
+				},
+				CompileOptions: map[CompileOption]bool{
+					InfixNotation:          true,
+					AllowUndefinedVariable: true,
+				},
+			},
+		},
+		{
+			expr: `a[]`,
+			cc: &Config{
+				CompileOptions: map[CompileOption]bool{
+					InfixNotation:          true,
+					AllowUndefinedVariable: true,
+				},
+			},
+			errMsg: "invalid expression error",
+		},
+		{
+			expr: `in(a, [1 (2)])`,
+			cc: &Config{
+				CompileOptions: map[CompileOption]bool{
+					InfixNotation:          true,
+					AllowUndefinedVariable: true,
+				},
+			},
+			errMsg: "invalid expression error",
+		},
+		{
+			expr: `in(a, [1 2]])`,
+			cc: &Config{
+				CompileOptions: map[CompileOption]bool{
+					InfixNotation:          true,
+					AllowUndefinedVariable: true,
+				},
+			},
+			errMsg: "parentheses unmatched error",
+		},
 	}
 
 	for _, c := range testCases {
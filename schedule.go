@@ -0,0 +1,297 @@
+package eval
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule is a small cron-like schedule, e.g. "MON-FRI 09:00-17:00", "MON#1" and "DEC20-JAN05".
+// The fields are separated by spaces, a time is in the schedule when it matches all the fields,
+// and the omitted fields match any time. The items of a field are separated by commas,
+// a time matches a field when it matches any of the items. There are three kinds of fields:
+//   - weekdays: SUN to SAT, the ranges e.g. MON-FRI, and the nth weekday of the month
+//     e.g. MON#1 (1 to 5, or L for the last one)
+//   - dates: the months JAN to DEC, the days e.g. DEC25, and the ranges e.g. JUN-AUG or DEC20-JAN05
+//   - clocks: the ranges e.g. 09:00-17:00, the end is exclusive and can be 24:00
+//
+// The names are case-insensitive, and the ranges can wrap around, e.g. FRI-MON, DEC20-JAN05 and 22:00-06:00,
+// the part after midnight of a wrapped clock range belongs to the weekday and the date of the previous day
+type schedule struct {
+	weekdays []weekdayRule
+	dates    []scheduleRange // the dates are month*100 + day, e.g. 1220 for DEC20
+	clocks   []scheduleRange // the clocks are the minutes of the day, the end is exclusive
+}
+
+type weekdayRule struct {
+	from, to time.Weekday
+	// nth is the nth weekday of the month, 0 is every one, and -1 is the last one
+	nth int
+}
+
+type scheduleRange struct {
+	from, to int
+}
+
+var (
+	weekdayNames = [...]string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+	monthNames   = [...]string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+	// monthDays are the max days of the months, Feb 29 is a valid date of the schedules
+	monthDays = [...]int{31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+)
+
+const minutesPerDay = 24 * 60
+
+func parseSchedule(s string) (*schedule, error) {
+	fields := strings.Fields(strings.ToUpper(s))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid schedule %q: empty schedule", s)
+	}
+
+	sc := &schedule{}
+	for _, f := range fields {
+		var (
+			err   error
+			items = strings.Split(f, ",")
+		)
+		switch {
+		case strings.Contains(f, ":"):
+			if sc.clocks != nil {
+				return nil, fmt.Errorf("invalid schedule %q: duplicate clocks field", s)
+			}
+			sc.clocks, err = parseScheduleItems(items, parseClockRange)
+		case nameIndex(weekdayNames[:], f) >= 0:
+			if sc.weekdays != nil {
+				return nil, fmt.Errorf("invalid schedule %q: duplicate weekdays field", s)
+			}
+			sc.weekdays, err = parseScheduleItems(items, parseWeekdayRule)
+		default:
+			if sc.dates != nil {
+				return nil, fmt.Errorf("invalid schedule %q: duplicate dates field", s)
+			}
+			sc.dates, err = parseScheduleItems(items, parseDateRange)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", s, err)
+		}
+	}
+	return sc, nil
+}
+
+func parseScheduleItems[T any](items []string, parse func(string) (T, error)) ([]T, error) {
+	res := make([]T, 0, len(items))
+	for _, item := range items {
+		v, err := parse(item)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+// nameIndex returns the index of the name which the string starts with, or -1
+func nameIndex(names []string, s string) int {
+	for i, name := range names {
+		if strings.HasPrefix(s, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseWeekdayRule parses the weekday items, e.g. MON, MON-FRI, MON#1 and FRI#L
+func parseWeekdayRule(s string) (weekdayRule, error) {
+	if name, nth, found := strings.Cut(s, "#"); found {
+		d := nameIndex(weekdayNames[:], name)
+		if d < 0 || len(name) != 3 {
+			return weekdayRule{}, fmt.Errorf("unknown weekday %s", name)
+		}
+
+		// the nth is 1 to 5, or L for the last one, the negative numbers are not accepted
+		n, err := strconv.Atoi(nth)
+		if nth == "L" {
+			n, err = -1, nil
+		} else if n < 1 {
+			err = strconv.ErrRange
+		}
+		if err != nil || n > 5 {
+			return weekdayRule{}, fmt.Errorf("invalid nth weekday %s", s)
+		}
+		return weekdayRule{from: time.Weekday(d), to: time.Weekday(d), nth: n}, nil
+	}
+
+	from, to, found := strings.Cut(s, "-")
+	if !found {
+		to = from
+	}
+	f, t := nameIndex(weekdayNames[:], from), nameIndex(weekdayNames[:], to)
+	if f < 0 || len(from) != 3 {
+		return weekdayRule{}, fmt.Errorf("unknown weekday %s", from)
+	}
+	if t < 0 || len(to) != 3 {
+		return weekdayRule{}, fmt.Errorf("unknown weekday %s", to)
+	}
+	return weekdayRule{from: time.Weekday(f), to: time.Weekday(t)}, nil
+}
+
+// parseDateRange parses the date items, e.g. DEC, DEC25, JUN-AUG and DEC20-JAN05
+func parseDateRange(s string) (scheduleRange, error) {
+	from, to, found := strings.Cut(s, "-")
+	if !found {
+		to = from
+	}
+
+	f, err := parseDate(from, false)
+	if err != nil {
+		return scheduleRange{}, err
+	}
+	t, err := parseDate(to, true)
+	if err != nil {
+		return scheduleRange{}, err
+	}
+	return scheduleRange{from: f, to: t}, nil
+}
+
+// parseDate parses the month with the optional day, the month is its first day, or its last day if it is the end
+func parseDate(s string, end bool) (int, error) {
+	m := nameIndex(monthNames[:], s)
+	if m < 0 {
+		return 0, fmt.Errorf("unknown date %s", s)
+	}
+
+	d := 1
+	if end {
+		d = monthDays[m]
+	}
+	if len(s) > 3 {
+		var err error
+		d, err = strconv.Atoi(s[3:])
+		if err != nil || d < 1 || d > monthDays[m] {
+			return 0, fmt.Errorf("invalid date %s", s)
+		}
+	}
+	return (m+1)*100 + d, nil
+}
+
+// parseClockRange parses the clock items, e.g. 09:00-17:00 and 22:00-06:00
+func parseClockRange(s string) (scheduleRange, error) {
+	from, to, found := strings.Cut(s, "-")
+	if !found {
+		return scheduleRange{}, fmt.Errorf("invalid clock range %s", s)
+	}
+
+	f, err := parseClock(from)
+	if err != nil || f == minutesPerDay {
+		return scheduleRange{}, fmt.Errorf("invalid clock %s", from)
+	}
+	t, err := parseClock(to)
+	if err != nil {
+		return scheduleRange{}, fmt.Errorf("invalid clock %s", to)
+	}
+	if f == t {
+		return scheduleRange{}, fmt.Errorf("empty clock range %s", s)
+	}
+	return scheduleRange{from: f, to: t}, nil
+}
+
+// parseClock parses the clock HH:MM into the minutes of the day, 24:00 is the end of the day
+func parseClock(s string) (int, error) {
+	hh, mm, found := strings.Cut(s, ":")
+	if !found || len(hh) != 2 || len(mm) != 2 {
+		return 0, strconv.ErrSyntax
+	}
+
+	h, err := strconv.Atoi(hh)
+	if err != nil {
+		return 0, err
+	}
+	m, err := strconv.Atoi(mm)
+	if err != nil {
+		return 0, err
+	}
+
+	if h < 0 || m < 0 || m >= 60 || h*60+m > minutesPerDay {
+		return 0, strconv.ErrRange
+	}
+	return h*60 + m, nil
+}
+
+// match checks whether the time is in the schedule, the time is matched in its own time zone,
+// e.g. MON 22:00-02:00 matches 01:00 on Tuesday, but not 01:00 on Monday
+func (s *schedule) match(t time.Time) bool {
+	if len(s.clocks) == 0 {
+		return s.matchDay(t)
+	}
+
+	clock := t.Hour()*60 + t.Minute()
+	for _, r := range s.clocks {
+		// the end of the clock range is exclusive
+		day := t
+		switch {
+		case r.from < r.to && clock >= r.from && clock < r.to:
+		case r.from > r.to && clock >= r.from:
+		case r.from > r.to && clock < r.to:
+			day = t.AddDate(0, 0, -1)
+		default:
+			continue
+		}
+
+		if s.matchDay(day) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *schedule) matchDay(t time.Time) bool {
+	return s.matchWeekday(t) && s.matchDate(t)
+}
+
+func (s *schedule) matchWeekday(t time.Time) bool {
+	if len(s.weekdays) == 0 {
+		return true
+	}
+
+	wd := int(t.Weekday())
+	for _, r := range s.weekdays {
+		if !inWrappedRange(wd, int(r.from), int(r.to)) {
+			continue
+		}
+
+		switch {
+		case r.nth == 0:
+			return true
+		case r.nth > 0 && (t.Day()-1)/7+1 == r.nth:
+			return true
+		case r.nth < 0 && t.AddDate(0, 0, 7).Month() != t.Month():
+			return true
+		}
+	}
+	return false
+}
+
+func (s *schedule) matchDate(t time.Time) bool {
+	if len(s.dates) == 0 {
+		return true
+	}
+
+	_, m, d := t.Date()
+	date := int(m)*100 + d
+	for _, r := range s.dates {
+		if inWrappedRange(date, r.from, r.to) {
+			return true
+		}
+	}
+	return false
+}
+
+// inWrappedRange checks whether the value is in the inclusive range, the range wraps around if from > to
+func inWrappedRange(v, from, to int) bool {
+	if from <= to {
+		return v >= from && v <= to
+	}
+	return v >= from || v <= to
+}
@@ -0,0 +1,377 @@
+package eval
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SemVer is a semantic version (https://semver.org), e.g. 2.3.4-beta.1+abc.
+// The versions are ordered by the SemVer 2.0 precedence, the build metadata is ignored
+type SemVer struct {
+	Major, Minor, Patch uint64
+	// Pre is the pre-release, e.g. "beta.1", a version with the pre-release precedes the normal version
+	Pre string
+	// Build is the build metadata, e.g. "abc"
+	Build string
+}
+
+// ParseSemVer parses the semantic version string, e.g. "2.3.4", "2.3.4-beta.1+abc"
+func ParseSemVer(s string) (SemVer, error) {
+	v, parts, err := parsePartialSemVer(s)
+	if err != nil {
+		return SemVer{}, err
+	}
+	if parts != 3 {
+		return SemVer{}, fmt.Errorf("invalid semver: %s", s)
+	}
+	return v, nil
+}
+
+// parsePartialSemVer parses the version whose minor and patch can be omitted or be the wildcards "*", "x" and "X",
+// e.g. "2", "2.3", "2.x", "2.3.*" and "*". It returns the count of the version numbers before the wildcards
+func parsePartialSemVer(s string) (SemVer, int, error) {
+	var v SemVer
+	rest, build, hasBuild := strings.Cut(s, "+")
+	rest, pre, hasPre := strings.Cut(rest, "-")
+	if (hasBuild && !validIdentifiers(build, false)) || (hasPre && !validIdentifiers(pre, true)) {
+		return SemVer{}, 0, fmt.Errorf("invalid semver: %s", s)
+	}
+	v.Pre, v.Build = pre, build
+
+	nums := strings.Split(rest, ".")
+	if len(nums) > 3 {
+		return SemVer{}, 0, fmt.Errorf("invalid semver: %s", s)
+	}
+
+	parts := len(nums)
+	for i, num := range nums {
+		if num == "*" || num == "x" || num == "X" {
+			if parts == len(nums) {
+				parts = i
+			}
+			continue
+		}
+		// the numbers after a wildcard should be the wildcards too, e.g. 2.x.x
+		if parts != len(nums) || !isNumericIdentifier(num) {
+			return SemVer{}, 0, fmt.Errorf("invalid semver: %s", s)
+		}
+
+		n, err := strconv.ParseUint(num, 10, 64)
+		if err != nil {
+			return SemVer{}, 0, fmt.Errorf("invalid semver: %s, %w", s, err)
+		}
+		switch i {
+		case 0:
+			v.Major = n
+		case 1:
+			v.Minor = n
+		default:
+			v.Patch = n
+		}
+	}
+
+	if (hasPre || hasBuild) && parts != 3 {
+		return SemVer{}, 0, fmt.Errorf("invalid semver: %s", s)
+	}
+	return v, parts, nil
+}
+
+// validIdentifiers checks the dot separated identifiers of the pre-release or the build metadata,
+// the identifiers are [0-9A-Za-z-], and the numeric identifiers of the pre-release have no leading zeros
+func validIdentifiers(s string, pre bool) bool {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		for i := 0; i < len(id); i++ {
+			c := id[i]
+			if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && c != '-' {
+				return false
+			}
+		}
+		if pre && isDigits(id) && !isNumericIdentifier(id) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNumericIdentifier checks the number without leading zeros, e.g. 0, 12
+func isNumericIdentifier(s string) bool {
+	return isDigits(s) && (s == "0" || s[0] != '0')
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (v SemVer) String() string {
+	s := strconv.FormatUint(v.Major, 10) + "." + strconv.FormatUint(v.Minor, 10) + "." + strconv.FormatUint(v.Patch, 10)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Cmp compares the two versions by the precedence, it returns -1 if v < o, 0 if v == o, +1 if v > o
+func (v SemVer) Cmp(o SemVer) int {
+	for _, p := range [...][2]uint64{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if p[0] != p[1] {
+			if p[0] < p[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	// the normal version has a higher precedence than the pre-release
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	}
+
+	a, b := v.Pre, o.Pre
+	for a != "" && b != "" {
+		var x, y string
+		x, a, _ = strings.Cut(a, ".")
+		y, b, _ = strings.Cut(b, ".")
+		if c := compareIdentifier(x, y); c != 0 {
+			return c
+		}
+	}
+
+	// a larger set of the pre-release identifiers has a higher precedence
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	}
+	return 1
+}
+
+// compareIdentifier compares the pre-release identifiers, the numeric identifiers are compared numerically,
+// and they have lower precedence than the alphanumeric identifiers, which are compared lexically
+func compareIdentifier(x, y string) int {
+	xNum, yNum := isDigits(x), isDigits(y)
+	switch {
+	case xNum && yNum:
+		// the numeric identifiers have no leading zeros, so the longer one is larger
+		if len(x) != len(y) {
+			if len(x) < len(y) {
+				return -1
+			}
+			return 1
+		}
+	case xNum:
+		return -1
+	case yNum:
+		return 1
+	}
+	return strings.Compare(x, y)
+}
+
+// versionConstraint is the ranges of the versions, e.g. ">=2.3.0 <3.0.0 || ^3.1".
+// The ranges are separated by "||", a version satisfies the constraint when it is in any of the ranges.
+// A range is the comparators separated by spaces, a version is in the range when it satisfies all of them.
+// The comparators are:
+//   - =, !=, >, >=, < and <= a version, e.g. >=2.3.0, the = can be omitted
+//   - ^ a version, it allows the changes which do not modify the left-most non-zero number,
+//     e.g. ^1.2.3 is >=1.2.3 <2.0.0-0 and ^0.2.3 is >=0.2.3 <0.3.0-0
+//   - ~ a version, it allows the patch changes, e.g. ~1.2.3 is >=1.2.3 <1.3.0-0
+//
+// The versions can be partial, the omitted numbers and the wildcards "*", "x" and "X" are any numbers,
+// e.g. 2.3 and 2.3.x are >=2.3.0 <2.4.0-0, <=2.3 is <2.4.0-0, and * is any version.
+//
+// A pre-release version is only in a range when a comparator of the range is a pre-release of the same
+// [major, minor, patch], e.g. 3.0.0-beta.1 is not in >=2.3.0 <3.0.0, but 3.0.0-beta.2 is in >=3.0.0-beta.1
+type versionConstraint [][]versionComparator
+
+type versionComparator struct {
+	mode    mode // equals, notEquals, greater, greaterEquals, less or lessEquals
+	version SemVer
+	// synthetic marks the bounds made from the partial versions, e.g. <3.0.0-0 of ^2.3,
+	// their pre-release 0 is not written by the users, so they do not allow the pre-releases
+	synthetic bool
+}
+
+var (
+	// versionOperators are the operators of the comparators, the longer ones are matched first
+	versionOperators = [...]string{">=", "<=", "!=", ">", "<", "=", "^", "~"}
+	// versionComparisons are the comparison modes of the primitive comparators
+	versionComparisons = map[string]mode{
+		"": equals, "=": equals, "!=": notEquals, ">": greater, ">=": greaterEquals, "<": less, "<=": lessEquals,
+	}
+)
+
+func parseVersionConstraint(s string) (versionConstraint, error) {
+	var res versionConstraint
+	for _, r := range strings.Split(s, "||") {
+		fields := strings.Fields(r)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid version constraint %q: empty range", s)
+		}
+
+		rng := make([]versionComparator, 0, len(fields))
+		for i := 0; i < len(fields); i++ {
+			f := fields[i]
+			// the operator can be separated from the version by spaces, e.g. ">= 2.3.0"
+			if isVersionOperator(f) && i+1 < len(fields) {
+				i++
+				f += fields[i]
+			}
+
+			cs, err := parseVersionComparator(f)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version constraint %q: %w", s, err)
+			}
+			rng = append(rng, cs...)
+		}
+		res = append(res, rng)
+	}
+	return res, nil
+}
+
+func isVersionOperator(s string) bool {
+	for _, op := range versionOperators {
+		if s == op {
+			return true
+		}
+	}
+	return false
+}
+
+// parseVersionComparator parses the comparator into the primitive comparators, e.g. ^1.2 is >=1.2.0 <2.0.0-0
+func parseVersionComparator(s string) ([]versionComparator, error) {
+	op := ""
+	for _, o := range versionOperators {
+		if strings.HasPrefix(s, o) {
+			op = o
+			break
+		}
+	}
+
+	v, parts, err := parsePartialSemVer(s[len(op):])
+	if err != nil {
+		return nil, err
+	}
+
+	// the upper is the smallest version which is larger than all the versions matching the partial version,
+	// e.g. 2.4.0-0 for 2.3, the pre-release 0 is the smallest one
+	upper := func(parts int) SemVer {
+		switch parts {
+		case 1:
+			return SemVer{Major: v.Major + 1, Pre: "0"}
+		case 2:
+			return SemVer{Major: v.Major, Minor: v.Minor + 1, Pre: "0"}
+		}
+		return SemVer{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1, Pre: "0"}
+	}
+
+	switch {
+	case parts == 0 && (op == ">" || op == "<" || op == "!="):
+		return nil, fmt.Errorf("invalid comparator %s", s)
+	case parts == 0:
+		// the wildcard matches any version
+		return nil, nil
+	case parts == 3 && op != "^" && op != "~":
+		return []versionComparator{{mode: versionComparisons[op], version: v}}, nil
+	}
+
+	// below is the synthetic upper bound of the partial version
+	below := func(parts int) versionComparator {
+		return versionComparator{mode: less, version: upper(parts), synthetic: true}
+	}
+
+	switch op {
+	case "", "=":
+		return []versionComparator{{mode: greaterEquals, version: v}, below(parts)}, nil
+	case ">":
+		return []versionComparator{{mode: greaterEquals, version: upper(parts), synthetic: true}}, nil
+	case ">=":
+		return []versionComparator{{mode: greaterEquals, version: v}}, nil
+	case "<":
+		lower := SemVer{Major: v.Major, Minor: v.Minor, Pre: "0"}
+		return []versionComparator{{mode: less, version: lower, synthetic: true}}, nil
+	case "<=":
+		return []versionComparator{below(parts)}, nil
+	case "~":
+		if parts == 3 {
+			parts = 2
+		}
+		return []versionComparator{{mode: greaterEquals, version: v}, below(parts)}, nil
+	case "^":
+		// keep the left-most non-zero number
+		switch {
+		case v.Major > 0 || parts == 1:
+			parts = 1
+		case v.Minor > 0 || parts == 2:
+			parts = 2
+		}
+		return []versionComparator{{mode: greaterEquals, version: v}, below(parts)}, nil
+	}
+	return nil, fmt.Errorf("invalid comparator %s", s)
+}
+
+// satisfied checks whether the version satisfies the constraint
+func (c versionConstraint) satisfied(v SemVer) bool {
+	for _, rng := range c {
+		if inVersionRange(rng, v) && (v.Pre == "" || allowsPreRelease(rng, v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsPreRelease checks whether the range has a pre-release comparator of the same [major, minor, patch],
+// so the pre-releases of the other versions are not matched unexpectedly
+func allowsPreRelease(rng []versionComparator, v SemVer) bool {
+	for _, c := range rng {
+		cv := c.version
+		if !c.synthetic && cv.Pre != "" && cv.Major == v.Major && cv.Minor == v.Minor && cv.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+func inVersionRange(rng []versionComparator, v SemVer) bool {
+	for _, c := range rng {
+		r := v.Cmp(c.version)
+		var ok bool
+		switch c.mode {
+		case equals:
+			ok = r == 0
+		case notEquals:
+			ok = r != 0
+		case greater:
+			ok = r > 0
+		case greaterEquals:
+			ok = r >= 0
+		case less:
+			ok = r < 0
+		case lessEquals:
+			ok = r <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
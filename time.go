@@ -0,0 +1,130 @@
+package eval
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	// the time zone database is embedded, so the time zones can be loaded without the system tzdata
+	_ "time/tzdata"
+)
+
+// Clock provides the current time of the evaluations, e.g. for now, today, since and until.
+// The tests and the replays can pin the current time with a fixed clock
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is an adapter to use the function as a Clock
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// FixedClock returns the clock which always returns the time
+func FixedClock(t time.Time) Clock {
+	return ClockFunc(func() time.Time {
+		return t
+	})
+}
+
+// locations caches the loaded time zones, the name -> *time.Location
+var locations sync.Map
+
+// loadLocation loads the time zone by the IANA name, e.g. "Asia/Tokyo", "UTC"
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// timeOf converts the time value to time.Time, the int64 value is the Unix seconds
+func timeOf(v Value) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case int64:
+		return time.Unix(t, 0).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// unixSeconds converts the times of the params to the Unix seconds
+func unixSeconds(params []Value) []Value {
+	res := make([]Value, len(params))
+	for i, p := range params {
+		if t, ok := p.(time.Time); ok {
+			p = t.Unix()
+		}
+		res[i] = p
+	}
+	return res
+}
+
+// timeIn converts the value to time.Time in the time zone of the optional tz param
+func timeIn(op string, v Value, tz ...Value) (time.Time, error) {
+	t, ok := timeOf(v)
+	if !ok {
+		return time.Time{}, ParamTypeError(op, typeTime, v)
+	}
+	if len(tz) == 0 {
+		return t, nil
+	}
+
+	name, ok := tz[0].(string)
+	if !ok {
+		return time.Time{}, ParamTypeError(op, typeStr, tz[0])
+	}
+	loc, err := loadLocation(name)
+	if err != nil {
+		return time.Time{}, OpExecError(op, err)
+	}
+	return t.In(loc), nil
+}
+
+const rfc3339NanoLayout = "2006-01-02T15:04:05.999999999Z07:00"
+
+// formatTime formats the time as the datetime operator call, the result can be compiled again,
+// e.g. (datetime "2021-01-01T09:00:00+09:00" "2006-01-02T15:04:05.999999999Z07:00" "Asia/Tokyo")
+func formatTime(t time.Time) string {
+	s := "(datetime " + strconv.Quote(t.Format(rfc3339NanoLayout)) + " " + strconv.Quote(rfc3339NanoLayout)
+	if name := t.Location().String(); name != "" {
+		if _, err := loadLocation(name); err == nil {
+			s += " " + strconv.Quote(name)
+		}
+	}
+	return s + ")"
+}
+
+// unixTimeVariable converts the time.Time value of the variable to the Unix seconds in the UnixTime mode
+func unixTimeVariable(n *node) Operator {
+	var (
+		fetch = n.operator
+		key   = n.varKey
+		name  = n.value.(string)
+	)
+	return func(ctx *Ctx, params []Value) (Value, error) {
+		var (
+			v   Value
+			err error
+		)
+		if fetch != nil {
+			v, err = fetch(ctx, params)
+		} else {
+			v, err = ctx.Get(key, name)
+		}
+
+		if t, ok := v.(time.Time); ok && err == nil {
+			return t.Unix(), nil
+		}
+		return v, err
+	}
+}
@@ -3,8 +3,11 @@ package eval
 import (
 	"fmt"
 	"math/rand"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -230,11 +233,14 @@ func GenerateTestCase(expr string, want Value, valMap map[string]interface{}) st
 			}
 		case int64:
 			return fmt.Sprintf("int64(%d)", v)
+		case float64:
+			return fmt.Sprintf("float64(%s)", formatFloat(v))
+		case Decimal:
+			return fmt.Sprintf("NewDecimal(%d, %d)", v.coef, v.scale)
+		case nil:
+			return "nil"
 		case string:
-			if strings.ContainsAny(v, `\n"`) {
-				return fmt.Sprintf("`%s`", v)
-			}
-			return fmt.Sprintf(`"%s"`, v)
+			return strconv.Quote(v)
 		default:
 			if v == DNE {
 				return "DNE"
@@ -272,7 +278,7 @@ func GenerateTestCase(expr string, want Value, valMap map[string]interface{}) st
 	}
 
 	expr = IndentByParentheses(expr)
-	if strings.ContainsRune(expr, '\n') {
+	if strings.ContainsRune(expr, '\n') && !strings.ContainsRune(expr, '`') {
 		expr = fmt.Sprintf("`\n%s`", expr)
 	} else {
 		expr = fmt.Sprintf(`            %s`, valStr(expr))
@@ -388,6 +394,21 @@ func IndentByParentheses(s string) string {
 				}
 			}
 			prev = comment
+		case c == '"' || c == '`':
+			// copy the string literal as it is
+			appendRune(c, prev, indent)
+			for i++; i < len(A); i++ {
+				sb.WriteRune(A[i])
+				if c == '"' && A[i] == '\\' && i+1 < len(A) {
+					i++
+					sb.WriteRune(A[i])
+					continue
+				}
+				if A[i] == c {
+					break
+				}
+			}
+			prev = normal
 		default:
 			appendRune(c, prev, indent)
 			prev = normal
@@ -400,19 +421,45 @@ func IndentByParentheses(s string) string {
 func Dump(e *Expr) string {
 	var getChildIdxes = func(idx int16) (res []int16) {
 		for i, p := range e.parentIdx {
-			if p == idx && e.nodes[i].getNodeType() != event {
+			switch n := e.nodes[i]; n.getNodeType() {
+			case event, loopHead, loopNext:
+				continue
+			case cond:
+				// skip the end if nodes
+				if n.value == "fi" {
+					continue
+				}
+			}
+			if p == idx {
 				res = append(res, int16(i))
 			}
 		}
+		return
+	}
+
+	type dumped struct {
+		str    string
+		isLeaf bool
+	}
 
-		if e.nodes[idx].getNodeType() == cond {
-			res = []int16{
-				res[0], // condition node
-				res[1], // true branch
-				res[3], // false branch
+	var compose = func(head string, children []dumped) string {
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("(%s", head))
+		for i, c := range children {
+			if c.isLeaf {
+				if i != 0 || head != "" {
+					sb.WriteString(" ")
+				}
+				sb.WriteString(c.str)
+				continue
+			}
+
+			for _, cs := range strings.Split(c.str, "\n") {
+				sb.WriteString(fmt.Sprintf("\n  %s", cs))
 			}
 		}
-		return
+		sb.WriteString(")")
+		return sb.String()
 	}
 
 	var helper func(int16) (string, bool)
@@ -423,24 +470,77 @@ func Dump(e *Expr) string {
 			return dumpLeafNode(n)
 		}
 
-		var sb strings.Builder
-		sb.WriteString(fmt.Sprintf("(%v", n.value))
-
-		childIdxes := getChildIdxes(idx)
-
-		for _, cIdx := range childIdxes {
+		var children []dumped
+		for _, cIdx := range getChildIdxes(idx) {
 			cc, isLeaf := helper(cIdx)
-			if isLeaf {
-				sb.WriteString(fmt.Sprintf(" %s", cc))
-				continue
+			children = append(children, dumped{str: cc, isLeaf: isLeaf})
+		}
+
+		// composeClause composes the clauses of cond and switch, e.g. (condition result)
+		var composeClause = func(head dumped, res dumped) string {
+			if strings.Contains(head.str, "\n") {
+				return compose("", []dumped{head, res})
 			}
+			return compose(head.str, []dumped{res})
+		}
 
-			for _, cs := range strings.Split(cc, "\n") {
-				sb.WriteString(fmt.Sprintf("\n  %s", cs))
+		switch n.getNodeType() {
+		case cond:
+			if n.value == keywordCond {
+				return composeClause(children[0], children[1]), false
+			}
+		case jumpTable:
+			table := n.value.(*switchTable)
+			clauses := make([]string, len(children))
+			for i, c := range children {
+				label := dumped{str: switchDefault}
+				if i < len(table.labels) {
+					label.str, _ = dumpLeafNode(&node{flag: constant, value: table.labels[i]})
+				}
+				clauses[i] = composeClause(label, c)
+			}
+			// the clauses are composed by the switch node
+			return strings.Join(clauses, "\n"), false
+		case multiCond:
+			if n.value == keywordSwitch {
+				// (switch subject (case1 res1) (case2 res2) (default res3))
+				return compose(string(keywordSwitch), children), false
 			}
+
+			// (cond (cond1 res1) (cond2 res2) (else res3))
+			l := len(children)
+			last := dumped{str: composeClause(dumped{str: condElse}, children[l-1])}
+			return compose(string(keywordCond), append(children[:l-1:l-1], last)), false
 		}
-		sb.WriteString(")")
-		return sb.String(), false
+
+		if n.getNodeType() != scope {
+			return compose(fmt.Sprint(n.value), children), false
+		}
+
+		info := n.value.(*keywordInfo)
+		if info.keyword == keywordReduce {
+			// (reduce acc init x coll body)
+			var (
+				acc = dumped{str: info.bindings[0].name, isLeaf: true}
+				x   = dumped{str: info.bindings[1].name, isLeaf: true}
+			)
+			return compose(string(info.keyword), []dumped{acc, children[1], x, children[0], children[2]}), false
+		}
+
+		if info.isLoop() {
+			// (any x coll body)
+			name := dumped{str: info.bindings[0].name, isLeaf: true}
+			return compose(string(info.keyword), append([]dumped{name}, children...)), false
+		}
+
+		// (let ((name1 expr1) (name2 expr2)) body)
+		bindings := make([]dumped, len(info.bindings))
+		for i, b := range info.bindings {
+			bindings[i] = dumped{str: compose(b.name, children[i:i+1])}
+		}
+
+		body := children[len(children)-1]
+		return compose(string(info.keyword), []dumped{{str: compose("", bindings)}, body}), false
 	}
 
 	var rootIdx int16
@@ -458,42 +558,88 @@ func dumpLeafNode(node *node) (string, bool) {
 	switch node.getNodeType() {
 	case event:
 		return "eventNode", false
-	case variable:
+	case variable, probe, local:
 		return fmt.Sprint(node.value), true
 	case operator, fastOperator:
 		return fmt.Sprintf("(%v)", node.value), false
 	}
 
-	var res string
-	switch v := node.value.(type) {
+	if secs, ok := node.value.(int64); ok && node.duration {
+		return formatDuration(secs), true
+	}
+	return dumpValue(node.value), true
+}
+
+// dumpValue formats the constant value, the result can be parsed as a literal,
+// e.g. (1 "a" true) for the list and {"CA": 8, "US": 10} for the map
+func dumpValue(val Value) string {
+	switch v := val.(type) {
+	case nil:
+		return "null"
 	case string:
-		res = strconv.Quote(v)
-	case []string:
-		var sb strings.Builder
+		return strconv.Quote(v)
+	case float64:
+		return formatFloat(v)
+	case Decimal:
+		return formatDecimal(v)
+	case time.Time:
+		return formatTime(v)
+	case SemVer:
+		return "(semver " + strconv.Quote(v.String()) + ")"
+	case []string, []int64, []float64, []Value:
+		var (
+			sb      strings.Builder
+			size, _ = listLen(v)
+		)
 		sb.WriteRune('(')
-		for idx, s := range v {
-			if idx != 0 {
+		for i := 0; i < size; i++ {
+			if i != 0 {
 				sb.WriteRune(' ')
 			}
-			sb.WriteString(strconv.Quote(s))
+			sb.WriteString(dumpValue(listElem(v, i)))
 		}
 		sb.WriteRune(')')
-		res = sb.String()
-	case []int64:
+		return sb.String()
+	case map[string]int64, map[string]float64, map[string]string, map[string]Value:
+		rv := reflect.ValueOf(v)
+		keys := make([]string, 0, rv.Len())
+		for _, k := range rv.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+
 		var sb strings.Builder
-		sb.WriteRune('(')
-		for idx, i := range v {
-			if idx != 0 {
-				sb.WriteRune(' ')
+		sb.WriteRune('{')
+		for i, k := range keys {
+			if i != 0 {
+				sb.WriteString(", ")
 			}
-			sb.WriteString(strconv.FormatInt(i, 10))
+			sb.WriteString(strconv.Quote(k))
+			sb.WriteString(": ")
+			sb.WriteString(dumpValue(rv.MapIndex(reflect.ValueOf(k)).Interface()))
 		}
-		sb.WriteRune(')')
-		res = sb.String()
-	default:
-		res = fmt.Sprint(v)
+		sb.WriteRune('}')
+		return sb.String()
+	}
+	return fmt.Sprint(val)
+}
+
+// formatFloat formats the float number, the result can be parsed as a float literal
+func formatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// formatDecimal formats the decimal as the literal, the decimal literal has a fraction,
+// so the integral decimal is formatted as the decimal operator call to keep the scale
+func formatDecimal(d Decimal) string {
+	if d.scale == 0 {
+		return "(decimal " + strconv.Quote(d.String()) + ")"
 	}
-	return res, true
+	return d.String() + "d"
 }
 
 func max(a, b int) int {
@@ -559,6 +705,20 @@ func DumpTable(expr *Expr, skipEventNode bool) string {
 			res = "C"
 		case cond:
 			res = "COND"
+		case local:
+			res = "L"
+		case scope:
+			res = "SCOP"
+		case loopHead:
+			res = "LOOP"
+		case loopNext:
+			res = "NEXT"
+		case multiCond:
+			res = "MCND"
+		case jumpTable:
+			res = "JTAB"
+		case probe:
+			res = "PROB"
 		case event:
 			res = "EVNT"
 		}
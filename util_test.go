@@ -189,6 +189,164 @@ func TestPrintCode(t *testing.T) {
   (overlap tags ("bbb" "aaa")))`)
 }
 
+func TestDump(t *testing.T) {
+	testCases := []struct {
+		expr string
+		want string
+	}{
+		{
+			expr: `(let ((a 1) (b (+ v a))) (and (> b a) (< b 10)))`,
+			want: `(let
+  (
+    (a 1)
+    (b
+      (+ v a)))
+  (and
+    (> b a)
+    (< b 10)))`,
+		},
+		{
+			expr: `(all x (1 2 3) (any y (3 2 1) (= x y)))`,
+			want: `(all x (1 2 3)
+  (any y (3 2 1)
+    (= x y)))`,
+		},
+		{
+			expr: `(map x (filter y (1 2 3) (> y v)) (* x 2))`,
+			want: `(map x
+  (filter y (1 2 3)
+    (> y v))
+  (* x 2))`,
+		},
+		{
+			expr: `(reduce acc (+ v 1) x (collect v 2) (+ acc x))`,
+			want: `(reduce acc
+  (+ v 1) x
+  (collect v 2)
+  (+ acc x))`,
+		},
+		{
+			expr: `(cond ((> v 10) 1) ((and (> v 5) (< v 8)) 2) (else (switch v (1 3) (default 4))))`,
+			want: `(cond
+  ((> v 10) 1)
+  (
+    (and
+      (> v 5)
+      (< v 8)) 2)
+  (else
+    (switch v
+      (1 3)
+      (default 4))))`,
+		},
+		{
+			expr: `(switch "a" ("a" (+ v 1)) ("b" 2) (default v))`,
+			want: `(switch "a"
+  ("a"
+    (+ v 1))
+  ("b" 2)
+  (default v))`,
+		},
+		{
+			expr: `(in (* v 1.0) (0.5 1 1e+21))`,
+			want: `(in
+  (* v 1.0) (0.5 1.0 1e+21))`,
+		},
+		{
+			expr: `(between (* v 1.25d) (decimal 5) -0.05d)`,
+			want: `(between
+  (* v 1.25d)
+  (decimal 5) -0.05d)`,
+		},
+		{
+			expr: "(concat \"say \\\"hi (\\\"\\n\" `C:\\dir (1)`)",
+			want: `(concat "say \"hi (\"\n" "C:\\dir (1)")`,
+		},
+		{
+			expr: `(and (exists v) (> (coalesce v 0) 1))`,
+			want: `(and
+  (exists v)
+  (>
+    (coalesce v 0) 1))`,
+		},
+		{
+			expr: `(if (is_null v) null v)`,
+			want: `(if
+  (is_null v) null v)`,
+		},
+		{
+			expr: `(and (< (since v) 1d12h) (> (until v) -90s) (= v 0s))`,
+			want: `(and
+  (<
+    (since v) 1d12h)
+  (>
+    (until v) -1m30s)
+  (= v 0s))`,
+		},
+		{
+			expr: `(in v ((1 "a") true null {"US": 10, "CA": 8} ()))`,
+			want: `(in v ((1 "a") true null {"CA": 8, "US": 10} ()))`,
+		},
+		{
+			expr: `(get {"b": (1 2.5), "a": {"x": v}} "a")`,
+			want: `(get
+  (dict "b" (1.0 2.5) "a"
+    (dict "x" v)) "a")`,
+		},
+		{
+			expr: `(let () (let ((a v)) a))`,
+			want: `(let
+  ()
+  (let
+    (
+      (a v)) a))`,
+		},
+	}
+
+	cc := NewConfig(
+		Optimizations(false),
+		RegVarAndOp(map[string]interface{}{
+			"v": 1,
+		}))
+
+	for _, c := range testCases {
+		t.Run(c.expr, func(t *testing.T) {
+			expr, err := Compile(cc, c.expr)
+			assertNil(t, err)
+
+			res := Dump(expr)
+			assertEquals(t, res, c.want)
+
+			// the dumped expression can be compiled again
+			_, err = Compile(cc, res)
+			assertNil(t, err)
+
+			// and it round-trips, e.g. the escaped strings are kept
+			expr, err = Compile(cc, IndentByParentheses(res))
+			assertNil(t, err)
+			assertEquals(t, Dump(expr), res)
+		})
+	}
+}
+
+func TestDump_FoldedDecimal(t *testing.T) {
+	cc := NewConfig(RegVarAndOp(map[string]interface{}{"v": 1}))
+	for expr, want := range map[string]string{
+		`(+ v (round 12.5d))`:  `(+ v (decimal "13"))`,
+		`(+ v (* 1.5d 2))`:     `(+ v 3.0d)`,
+		`(+ v (decimal "-7"))`: `(+ v (decimal "-7"))`,
+	} {
+		e, err := Compile(cc, expr)
+		assertNil(t, err)
+		res := Dump(e)
+		assertEquals(t, res, want)
+
+		// the scale of the integral decimal is kept after the round trip
+		e, err = Compile(cc, res)
+		assertNil(t, err)
+		assertEquals(t, Dump(e), want)
+	}
+}
+
 func TestGenerateRandomExpr_Bool(t *testing.T) {
 	const size = 50
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -376,6 +534,19 @@ func TestGenerateTestCase(t *testing.T) {
             optimizeLevel: disable,
             s:             "(+ 1 1)",
             valMap:        nil,
+        },`,
+		},
+		{
+			expr: GenExprResult{
+				Expr: "(concat `\\d` \"\\t\\\"\")",
+				Res:  "\\d\t\"",
+			},
+			want: `
+        {
+            want:          "\\d\t\"",
+            optimizeLevel: disable,
+            s:             "(concat ` + "`\\\\d`" + ` \"\\t\\\"\")",
+            valMap:        nil,
         },`,
 		},
 		{
@@ -11,6 +11,7 @@ var (
 	builtinConstants = map[string]Value{
 		"true":  true,
 		"false": false,
+		"null":  nil,
 	}
 )
 
@@ -29,6 +30,14 @@ var DNE = dne{DoesNotExist: "DNE"}
 
 var ErrDNE = errors.New("DNE")
 
+// missing is the value of the probed variable which can not be fetched,
+// it is only passed to the special forms (exists, default and coalesce)
+type missingValue struct{}
+
+func (missingValue) String() string { return "missing" }
+
+var missing = missingValue{}
+
 // VariableFetcher is used to fetch values of the expression variables.
 // Note that there are two types of keys in each method parameters,
 // The varKey is of type VariableKey, the strKey is of type string,
@@ -102,11 +111,16 @@ func varKeyRange(cc *Config) (min, max VariableKey) {
 	return
 }
 
+// SliceVarFetcher stores the values by the variable keys, the keys without values are missing,
+// as the absent keys of MapVarFetcher
 type SliceVarFetcher []Value
 
 func NewSliceVarFetcher(cc *Config, vals map[string]interface{}) SliceVarFetcher {
 	_, maxKey := varKeyRange(cc)
 	fetcher := make([]Value, maxKey+1)
+	for i := range fetcher {
+		fetcher[i] = missing
+	}
 
 	for name, key := range cc.VariableKeyMap {
 		if val, exist := vals[name]; exist {
@@ -118,14 +132,14 @@ func NewSliceVarFetcher(cc *Config, vals map[string]interface{}) SliceVarFetcher
 }
 
 func (s SliceVarFetcher) Get(key VariableKey, _ string) (Value, error) {
-	if int(key) >= len(s) {
+	if key < 0 || int(key) >= len(s) || s[key] == missing {
 		return nil, fmt.Errorf("variableKey not exist %d", key)
 	}
 	return s[key], nil
 }
 
 func (s SliceVarFetcher) Set(key VariableKey, _ string, val Value) error {
-	if int(key) >= len(s) {
+	if key < 0 || int(key) >= len(s) {
 		return fmt.Errorf("variableKey not exist %d", key)
 	}
 	s[key] = val
@@ -133,10 +147,7 @@ func (s SliceVarFetcher) Set(key VariableKey, _ string, val Value) error {
 }
 
 func (s SliceVarFetcher) Cached(key VariableKey, _ string) bool {
-	if int(key) >= len(s) {
-		return false
-	}
-	return true
+	return key >= 0 && int(key) < len(s) && s[key] != missing
 }
 
 type MapVarFetcher map[string]Value
@@ -169,7 +180,7 @@ func (s MapVarFetcher) Cached(_ VariableKey, key string) bool {
 
 func UnifyType(val Value) Value {
 	switch val.(type) {
-	case bool, string, int64, []int64, []string:
+	case bool, string, int64, float64, Decimal, []int64, []float64, []string:
 		return val
 	default:
 		return unifyType(val)
@@ -180,8 +191,8 @@ func unifyType(val Value) Value {
 	switch v := val.(type) {
 	case int:
 		return int64(v)
-	case time.Time:
-		return v.Unix()
+	case uint:
+		return int64(v)
 	case time.Duration:
 		return int64(v / time.Second)
 	case []int:
@@ -210,6 +221,19 @@ func unifyType(val Value) Value {
 		return int64(v)
 	case uint8:
 		return int64(v)
+	case float32:
+		return float64(v)
+	case *Decimal:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case []float32:
+		temp := make([]float64, len(v))
+		for i, fv := range v {
+			temp[i] = float64(fv)
+		}
+		return temp
 	}
 	return val
 }